@@ -0,0 +1,71 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "errors"
+    "reflect"
+    "strings"
+    "unicode"
+    "encoding/base64"
+)
+
+// the width of the lines of base64 block scalars
+const binaryLineWidth = 76
+
+// byte slices and arrays are binary data
+func isBinaryType(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.Slice, reflect.Array:
+        return t.Elem().Kind() == reflect.Uint8
+    }
+    return false
+}
+
+// store bytes to a byte slice or an array of the same length
+func setBytes(rv reflect.Value, data []byte) error {
+
+    if rv.Kind() == reflect.Slice {
+        // the element type might be a named byte type
+        bv := reflect.MakeSlice(rv.Type(), len(data), len(data))
+        reflect.Copy(bv, reflect.ValueOf(data))
+        rv.Set(bv)
+        return nil
+    }
+
+    if len(data) != rv.Len() {
+        return errors.New(fmt.Sprintf("cannot store %d bytes to an array of %d", len(data), rv.Len()))
+    }
+    for i, b := range data {
+        rv.Index(i).SetUint(uint64(b))
+    }
+    return nil
+}
+
+// decode base64 content; whitespace (i.e. line breaks) is ignored
+func decodeBinary(str string) ([]byte, error) {
+    str = strings.Map(func(r rune) rune {
+        if unicode.IsSpace(r) {
+            return -1
+        }
+        return r
+    }, str)
+    return base64.StdEncoding.DecodeString(str)
+}
+
+// encode to base64; with a width the content is split in lines
+func encodeBinary(data []byte, width int) string {
+    str := base64.StdEncoding.EncodeToString(data)
+    if width <= 0 || len(str) <= width {
+        return str
+    }
+
+    var sb strings.Builder
+    for len(str) > width {
+        sb.WriteString(str[:width])
+        sb.WriteByte('\n')
+        str = str[width:]
+    }
+    sb.WriteString(str)
+    return sb.String()
+}
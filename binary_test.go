@@ -0,0 +1,237 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "bytes"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestDecodeBinaryText(t *testing.T) {
+
+    tests := []struct {
+        str string
+        expected []byte
+        fails bool
+    }{
+        { str: "aGVsbG8=", expected: []byte("hello") },
+        { str: "aGVs\nbG8=\n", expected: []byte("hello") },
+        { str: "  aGVs bG8=\t", expected: []byte("hello") },
+        { str: "", expected: []byte{} },
+        { str: "aGVsbG8", fails: true },
+        { str: "not base64!", fails: true },
+    }
+
+    for _, tt := range tests {
+        data, err := decodeBinary(tt.str)
+        if tt.fails {
+            if err == nil {
+                t.Errorf("%q: expected an error, got %q", tt.str, data)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", tt.str, err)
+            continue
+        }
+        if !bytes.Equal(data, tt.expected) {
+            t.Errorf("%q: got %q, expected %q", tt.str, data, tt.expected)
+        }
+    }
+}
+
+func TestEncodeBinary(t *testing.T) {
+
+    data := bytes.Repeat([]byte{0, 1, 2, 0xfe, 0xff}, 40)
+
+    for _, width := range []int{0, 8, 76, 1000} {
+        str := encodeBinary(data, width)
+
+        for _, line := range strings.Split(str, "\n") {
+            if width > 0 && len(line) > width {
+                t.Errorf("width %d: line of %d", width, len(line))
+            }
+        }
+        if width == 0 && strings.Contains(str, "\n") {
+            t.Errorf("width 0: split in lines")
+        }
+
+        back, err := decodeBinary(str)
+        if err != nil {
+            t.Fatalf("width %d: %v", width, err)
+        }
+        if !bytes.Equal(back, data) {
+            t.Errorf("width %d: round trip failed", width)
+        }
+    }
+
+    if str := encodeBinary(nil, 76); str != "" {
+        t.Errorf("got %q for no data", str)
+    }
+}
+
+type octets []byte
+
+func TestSetBytes(t *testing.T) {
+
+    tests := []struct {
+        name string
+        into interface{}
+        expected interface{}
+        fails bool
+    }{
+        { name: "slice", into: new([]byte), expected: []byte("abc") },
+        { name: "named slice", into: new(octets), expected: octets("abc") },
+        { name: "array", into: new([3]byte), expected: [3]byte{'a', 'b', 'c'} },
+        { name: "short array", into: new([2]byte), fails: true },
+        { name: "long array", into: new([4]byte), fails: true },
+    }
+
+    for _, tt := range tests {
+        err := setBytes(reflect.ValueOf(tt.into).Elem(), []byte("abc"))
+        if tt.fails {
+            if err == nil {
+                t.Errorf("%s: expected an error", tt.name)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if got := reflect.ValueOf(tt.into).Elem().Interface(); !reflect.DeepEqual(got, tt.expected) {
+            t.Errorf("%s: got %#v, expected %#v", tt.name, got, tt.expected)
+        }
+    }
+}
+
+type keyed struct {
+    Cert []byte `yaml:"cert"`
+    Sum [4]byte `yaml:"sum"`
+}
+
+func TestDecodeBinary(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "tagged",
+            input: "!!binary aGVsbG8=\n",
+            into: new([]byte),
+            expected: []byte("hello"),
+        }, {
+            name: "tagged block",
+            input: "cert: !!binary |\n  aGVs\n  bG8=\nsum: !!binary AAECAw==\n",
+            into: new(keyed),
+            expected: keyed{Cert: []byte("hello"), Sum: [4]byte{0, 1, 2, 3}},
+        }, {
+            name: "tagged to generic",
+            input: "!!binary aGVsbG8=\n",
+            into: new(interface{}),
+            expected: []byte("hello"),
+        }, {
+            name: "untagged is text",
+            input: "cert: aGVsbG8=\n",
+            into: new(keyed),
+            expected: keyed{Cert: []byte("aGVsbG8=")},
+        }, {
+            name: "untagged json is base64",
+            input: `{"cert": "aGVsbG8="}`,
+            opts: []interface{}{"schema=json"},
+            into: new(keyed),
+            expected: keyed{Cert: []byte("hello")},
+        }, {
+            name: "named slice",
+            input: "!!binary aGVsbG8=\n",
+            into: new(octets),
+            expected: octets("hello"),
+        }, {
+            name: "bad base64",
+            input: "cert: !!binary not base64\n",
+            into: new(keyed),
+            fails: true,
+        }, {
+            name: "array length mismatch",
+            input: "sum: !!binary aGVsbG8=\n",
+            into: new(keyed),
+            fails: true,
+        }, {
+            name: "binary to a number",
+            input: "!!binary aGVsbG8=\n",
+            into: new(int),
+            fails: true,
+        },
+    })
+}
+
+func TestMarshalBinary(t *testing.T) {
+
+    big := bytes.Repeat([]byte("0123456789"), 20)
+
+    tests := []struct {
+        name string
+        value interface{}
+        opts []interface{}
+        contains []string
+        excludes []string
+    }{
+        {
+            name: "block",
+            value: map[string][]byte{"cert": big},
+            contains: []string{"!!binary |"},
+        }, {
+            name: "flow",
+            value: map[string][]byte{"cert": []byte("hello")},
+            opts: []interface{}{"output-mode=flow"},
+            contains: []string{"!!binary", "aGVsbG8="},
+            excludes: []string{"|"},
+        }, {
+            name: "json",
+            value: map[string][]byte{"cert": []byte("hello")},
+            opts: []interface{}{"output-mode=json"},
+            contains: []string{`"aGVsbG8="`},
+            excludes: []string{"!!binary"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value, tt.opts...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, s := range tt.contains {
+                if !strings.Contains(string(data), s) {
+                    t.Errorf("%q missing from:\n%s", s, data)
+                }
+            }
+            for _, s := range tt.excludes {
+                if strings.Contains(string(data), s) {
+                    t.Errorf("unexpected %q in:\n%s", s, data)
+                }
+            }
+        })
+    }
+
+    value := keyed{Cert: big, Sum: [4]byte{1, 2, 3, 4}}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "round trip",
+            value: value,
+            back: new(keyed),
+            expected: value,
+        }, {
+            name: "round trip to generic",
+            value: []byte("hello"),
+            back: new(interface{}),
+            expected: []byte("hello"),
+        }, {
+            name: "empty",
+            value: []byte{},
+            back: new([]byte),
+            expected: []byte{},
+        },
+    })
+}
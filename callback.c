@@ -1,4 +1,5 @@
 #include <stdlib.h>
+#include <string.h>
 #include <libfyaml.h>
 
 extern enum fy_composer_return
@@ -10,6 +11,24 @@ compose_process_event(struct fy_parser *fyp, struct fy_event *fye, struct fy_pat
 	return FY_ProcessEvent(fyp, fye, path, userdata);
 }
 
+extern ssize_t
+FY_InputRead(void *user, void *buf, size_t count);
+
+ssize_t
+input_read(void *user, void *buf, size_t count)
+{
+	return FY_InputRead(user, buf, count);
+}
+
+extern int
+FY_EmitterOutput(struct fy_emitter *emit, enum fy_emitter_write_type type, const char *str, int len, void *userdata);
+
+int
+emitter_output(struct fy_emitter *emit, enum fy_emitter_write_type type, const char *str, int len, void *userdata)
+{
+	return FY_EmitterOutput(emit, type, str, len, userdata);
+}
+
 struct fy_event *
 fy_emit_event_create_simple(struct fy_emitter *emit, enum fy_event_type type)
 {
@@ -45,3 +64,44 @@ fy_emit_event_create_alias(struct fy_emitter *emit, const char *value)
 {
     return fy_emit_event_create(emit, FYET_ALIAS, value);
 }
+
+/* strings that live as long as a document (libfyaml does not copy tags) */
+struct doc_string {
+	struct doc_string *next;
+	char str[];
+};
+
+static void
+doc_strings_free(struct fy_document *fyd, void *userdata)
+{
+	struct doc_string *ds, *dsn;
+
+	for (ds = userdata; ds; ds = dsn) {
+		dsn = ds->next;
+		free(ds);
+	}
+}
+
+const char *
+document_keep_string(struct fy_document *fyd, const char *str, size_t len)
+{
+	struct doc_string *ds, *head;
+
+	ds = malloc(sizeof(*ds) + len + 1);
+	if (!ds)
+		return NULL;
+	memcpy(ds->str, str, len);
+	ds->str[len] = '\0';
+
+	/* the first string registers the release on destroy */
+	head = fy_document_get_userdata(fyd);
+	if (!head && fy_document_register_on_destroy(fyd, doc_strings_free)) {
+		free(ds);
+		return NULL;
+	}
+
+	ds->next = head;
+	fy_document_set_userdata(fyd, ds);
+
+	return ds->str;
+}
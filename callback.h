@@ -7,6 +7,12 @@
 extern enum fy_composer_return
 compose_process_event(struct fy_parser *fyp, struct fy_event *fye, struct fy_path *path, void *userdata);
 
+extern ssize_t
+input_read(void *user, void *buf, size_t count);
+
+extern int
+emitter_output(struct fy_emitter *emit, enum fy_emitter_write_type type, const char *str, int len, void *userdata);
+
 extern struct fy_event *
 fy_emit_event_create_simple(struct fy_emitter *emit, enum fy_event_type type);
 
@@ -25,4 +31,7 @@ fy_emit_event_create_scalar(struct fy_emitter *emit, enum fy_scalar_style ss, co
 extern struct fy_event *
 fy_emit_event_create_alias(struct fy_emitter *emit, const char *value);
 
+extern const char *
+document_keep_string(struct fy_document *fyd, const char *str, size_t len);
+
 #endif
@@ -0,0 +1,435 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "time"
+    "errors"
+    "reflect"
+    "strconv"
+    "encoding"
+)
+
+// converts values that are already decoded to another type
+// collections are walked by reflection, while scalars go through the
+// scalar tag handlers of the schema; nothing is parsed again, so the
+// decoder limits and errors are those of the original input
+type Converter struct {
+    si SchemaImplementer
+    tsp TypeServicesProvider
+    opts *Options
+}
+
+func NewConverter(si SchemaImplementer, tsp TypeServicesProvider, opts *Options) *Converter {
+
+    // a standalone converter has its own struct cache
+    if tsp == nil {
+        tsp = NewStructCache(opts)
+    }
+
+    return &Converter{
+        si: si,
+        tsp: tsp,
+        opts: opts,
+    }
+}
+
+// a converter using the services of the decoder of the path
+func NewPathConverter(path *Path, si SchemaImplementer) *Converter {
+    tsp, _ := path.RootUserData().(TypeServicesProvider)
+    return NewConverter(si, tsp, GetObjectOptions(path.RootUserData()))
+}
+
+// the unmarshal function given to custom unmarshalers; v must be a pointer
+func (c *Converter) UnmarshalFunc(src reflect.Value) func(interface{}) error {
+    return func(v interface{}) error {
+        pv := reflect.ValueOf(v)
+        if pv.Kind() != reflect.Ptr || pv.IsNil() {
+            return errors.New(fmt.Sprintf("cannot unmarshal to non pointer %T", v))
+        }
+        return c.Convert(pv.Elem(), src)
+    }
+}
+
+// store src to rv, converting it to the type of rv
+func (c *Converter) Convert(rv, src reflect.Value) error {
+
+    if !rv.CanSet() {
+        return errors.New(fmt.Sprintf("cannot address to store %s", rv.Type()))
+    }
+
+    // generic values convert what they hold
+    for src.Kind() == reflect.Interface && !src.IsNil() {
+        src = src.Elem()
+    }
+
+    if isNullValue(src) {
+        rv.Set(reflect.Zero(rv.Type()))
+        return nil
+    }
+
+    // the simple case
+    if src.Type().AssignableTo(rv.Type()) {
+        rv.Set(src)
+        return nil
+    }
+
+    // pointer targets
+    if rv.Kind() == reflect.Ptr {
+
+        // point to the value itself
+        if c.opts.SharePointers && src.CanAddr() && src.Addr().Type() == rv.Type() {
+            rv.Set(src.Addr())
+            return nil
+        }
+
+        // or to a converted copy
+        nv := reflect.New(rv.Type().Elem())
+        if err := c.Convert(nv.Elem(), src); err != nil {
+            return err
+        }
+        rv.Set(nv)
+        return nil
+    }
+
+    // pointer sources
+    if src.Kind() == reflect.Ptr {
+        return c.Convert(rv, src.Elem())
+    }
+
+    if isCustom, err := c.convertCustom(rv, src); isCustom {
+        return err
+    }
+
+    switch rv.Kind() {
+    case reflect.Interface:
+        // an interface the value does not implement
+        return mismatchError(rv, src)
+
+    case reflect.Struct:
+        if rv.Type() != timeType {
+            return c.convertStruct(rv, src)
+        }
+
+    case reflect.Map:
+        return c.convertMap(rv, src)
+
+    case reflect.Slice, reflect.Array:
+        if isPairsType(rv.Type()) {
+            return c.convertPairs(rv, src)
+        }
+        // plain text to bytes
+        if isBinaryType(rv.Type()) && src.Kind() == reflect.String {
+            return c.convertSeq(rv, reflect.ValueOf([]byte(src.String())))
+        }
+        return c.convertSeq(rv, src)
+    }
+
+    return c.convertScalar(rv, src)
+}
+
+// nil values of any kind are nulls
+func isNullValue(rv reflect.Value) bool {
+    switch rv.Kind() {
+    case reflect.Invalid:
+        return true
+    case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+        return rv.IsNil()
+    }
+    return false
+}
+
+func mismatchError(rv, src reflect.Value) error {
+    return errors.New(fmt.Sprintf("cannot convert %s to %s", src.Type(), rv.Type()))
+}
+
+// the custom unmarshalers of the target (if enabled)
+func (c *Converter) convertCustom(rv, src reflect.Value) (bool, error) {
+
+    if !c.opts.Custom || rv.Kind() == reflect.Interface || !rv.CanAddr() {
+        return false, nil
+    }
+
+    // time values are handled by the schema
+    if rv.Type() == timeType || rv.Type() == durationType {
+        return false, nil
+    }
+
+    pt := reflect.PtrTo(rv.Type())
+
+    if pt.Implements(unmarshalerType) {
+        u := rv.Addr().Interface().(Unmarshaler)
+        return true, u.UnmarshalYAML(c.UnmarshalFunc(src))
+    }
+
+    if src.Kind() == reflect.String && pt.Implements(textUnmarshalerType) {
+        tu := rv.Addr().Interface().(encoding.TextUnmarshaler)
+        return true, tu.UnmarshalText([]byte(src.String()))
+    }
+
+    return false, nil
+}
+
+// the keys of a map in the natural order, so that the result of a
+// conversion does not change from run to run
+func naturalMapKeys(rv reflect.Value) []reflect.Value {
+    keys := rv.MapKeys()
+    sort.SliceStable(keys, func(i, j int) bool {
+        return NaturalKeyLess(keys[i].Interface(), keys[j].Interface())
+    })
+    return keys
+}
+
+// the keys and values of a mapping value; false if it's not one
+// structs are mappings of their fields, as they are encoded
+func (c *Converter) mappingPairs(src reflect.Value) ([]reflect.Value, []reflect.Value, bool) {
+
+    var keys, values []reflect.Value
+
+    switch {
+    case src.Kind() == reflect.Map:
+        keys = naturalMapKeys(src)
+        for _, key := range keys {
+            values = append(values, src.MapIndex(key))
+        }
+
+    case isPairsType(src.Type()):
+        for i := 0; i < src.Len(); i++ {
+            item := src.Index(i)
+            keys = append(keys, item.Field(0))
+            values = append(values, item.Field(1))
+        }
+
+    case src.Kind() == reflect.Struct && src.Type() != timeType:
+        ti := c.tsp.LookupOrNewType(src.Type())
+        for _, ff := range ti.fields {
+            if ff.ignored {
+                continue
+            }
+            rvf := FieldByIndexRead(src, ff.index)
+            if !rvf.IsValid() || (ff.omitempty && IsEmptyValue(rvf)) {
+                continue
+            }
+            keys = append(keys, reflect.ValueOf(ff.name))
+            values = append(values, rvf)
+        }
+        if ti.inlineMap != nil {
+            rvm := FieldByIndexRead(src, ti.inlineMap.index)
+            if rvm.IsValid() && !rvm.IsNil() {
+                for _, key := range naturalMapKeys(rvm) {
+                    keys = append(keys, key)
+                    values = append(values, rvm.MapIndex(key))
+                }
+            }
+        }
+
+    default:
+        return nil, nil, false
+    }
+
+    return keys, values, true
+}
+
+func (c *Converter) convertStruct(rv, src reflect.Value) error {
+
+    keys, values, isMapping := c.mappingPairs(src)
+    if !isMapping {
+        return mismatchError(rv, src)
+    }
+
+    ti := c.tsp.LookupOrNewType(rv.Type())
+
+    for i, key := range keys {
+
+        // fields are addressed by name only
+        for key.Kind() == reflect.Interface && !key.IsNil() {
+            key = key.Elem()
+        }
+        if key.Kind() != reflect.String {
+            return errors.New(fmt.Sprintf("key %v is not a string for %s", key, rv.Type()))
+        }
+        name := key.String()
+
+        rvf, _, err := ti.FieldByName(name, &rv)
+        if err != nil {
+            return err
+        }
+
+        if rvf != nil {
+            if err := c.Convert(*rvf, values[i]); err != nil {
+                return fmt.Errorf("field %s: %w", name, err)
+            }
+            continue
+        }
+
+        if ti.inlineMap == nil {
+            return errors.New(fmt.Sprintf("illegal key field %s for %s", name, rv.Type()))
+        }
+
+        // no field matched, it goes to the inline map
+        rvm, err := FieldByIndexAlloc(rv, ti.inlineMap.index)
+        if err != nil {
+            return err
+        }
+        if err := c.setMapIndex(rvm, key, values[i]); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (c *Converter) convertMap(rv, src reflect.Value) error {
+
+    keys, values, isMapping := c.mappingPairs(src)
+    if !isMapping {
+        return mismatchError(rv, src)
+    }
+
+    for i, key := range keys {
+        if err := c.setMapIndex(rv, key, values[i]); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// convert and store a key and value to a map (created if nil)
+func (c *Converter) setMapIndex(rvm, key, value reflect.Value) error {
+
+    if rvm.IsNil() {
+        rvm.Set(reflect.MakeMap(rvm.Type()))
+    }
+
+    rvk := reflect.New(rvm.Type().Key()).Elem()
+    if err := c.Convert(rvk, key); err != nil {
+        return fmt.Errorf("key %v: %w", key, err)
+    }
+
+    // complex keys of generic maps take their canonical form
+    if !IsHashable(rvk) {
+        if rvk.Kind() != reflect.Interface {
+            return errors.New(fmt.Sprintf("unhashable key of type %s", rvk.Type()))
+        }
+        ckey, err := CanonicalKey(rvk)
+        if err != nil {
+            return err
+        }
+        rvk = ckey
+    }
+
+    rvv := reflect.New(rvm.Type().Elem()).Elem()
+    if err := c.Convert(rvv, value); err != nil {
+        return fmt.Errorf("key %v: %w", key, err)
+    }
+
+    rvm.SetMapIndex(rvk, rvv)
+
+    return nil
+}
+
+// ordered mappings (and pairs) keep the order of the source
+func (c *Converter) convertPairs(rv, src reflect.Value) error {
+
+    keys, values, isMapping := c.mappingPairs(src)
+    if !isMapping {
+        return mismatchError(rv, src)
+    }
+
+    items := reflect.MakeSlice(rv.Type(), 0, len(keys))
+    for i, key := range keys {
+        item := MapItem{
+            Key: key.Interface(),
+            Value: values[i].Interface(),
+        }
+        items = reflect.Append(items, reflect.ValueOf(item))
+    }
+    rv.Set(items)
+
+    return nil
+}
+
+func (c *Converter) convertSeq(rv, src reflect.Value) error {
+
+    if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+        return mismatchError(rv, src)
+    }
+
+    n := src.Len()
+    if rv.Kind() == reflect.Array {
+        if n > rv.Len() {
+            return errors.New(fmt.Sprintf("cannot store %d items to an array of %d", n, rv.Len()))
+        }
+    } else {
+        rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+    }
+
+    for i := 0; i < n; i++ {
+        if err := c.Convert(rv.Index(i), src.Index(i)); err != nil {
+            return fmt.Errorf("item %d: %w", i, err)
+        }
+    }
+
+    return nil
+}
+
+// scalars are converted to text and stored by the tag handler of the
+// schema, so that the range checks and the rules are those of decoding
+func (c *Converter) convertScalar(rv, src reflect.Value) error {
+
+    text, isScalar := scalarText(src)
+    if !isScalar {
+        return mismatchError(rv, src)
+    }
+
+    th, _ := c.si.ResolveScalar(nil, &text, rv.Kind())
+    ts, hasTs := th.(ScalarTextSetter)
+    if !hasTs {
+        return mismatchError(rv, src)
+    }
+
+    return ts.SetScalarText(rv, &text)
+}
+
+// the text of a decoded scalar, as the schemas read it back
+func scalarText(rv reflect.Value) (string, bool) {
+
+    switch rv.Kind() {
+    case reflect.String:
+        return rv.String(), true
+
+    case reflect.Bool:
+        return strconv.FormatBool(rv.Bool()), true
+
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if rv.Type() == durationType {
+            return time.Duration(rv.Int()).String(), true
+        }
+        return strconv.FormatInt(rv.Int(), 10), true
+
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return strconv.FormatUint(rv.Uint(), 10), true
+
+    case reflect.Float32, reflect.Float64:
+        f := rv.Float()
+        switch {
+        case math.IsNaN(f):
+            return ".nan", true
+        case math.IsInf(f, 1):
+            return ".inf", true
+        case math.IsInf(f, -1):
+            return "-.inf", true
+        }
+        return strconv.FormatFloat(f, 'g', -1, rv.Type().Bits()), true
+
+    case reflect.Struct:
+        if rv.Type() == timeType {
+            return rv.Interface().(time.Time).Format(time.RFC3339Nano), true
+        }
+    }
+
+    return "", false
+}
@@ -0,0 +1,320 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "errors"
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+var errCustom = errors.New("custom failure")
+
+// unmarshals from a string, to upper case
+type upper string
+
+func (u *upper) UnmarshalYAML(unmarshal func(interface{}) error) error {
+    var s string
+    if err := unmarshal(&s); err != nil {
+        return err
+    }
+    *u = upper(strings.ToUpper(s))
+    return nil
+}
+
+func (u upper) MarshalYAML() (interface{}, error) {
+    return strings.ToLower(string(u)), nil
+}
+
+// unmarshals from either a number or a mapping
+type temperature struct {
+    Celsius float64 `yaml:"celsius"`
+}
+
+func (tp *temperature) UnmarshalYAML(unmarshal func(interface{}) error) error {
+    var deg float64
+    if err := unmarshal(&deg); err == nil {
+        tp.Celsius = deg
+        return nil
+    }
+    type plain temperature
+    return unmarshal((*plain)(tp))
+}
+
+// always fails
+type broken struct {
+}
+
+func (b *broken) UnmarshalYAML(unmarshal func(interface{}) error) error {
+    return errCustom
+}
+
+func (b broken) MarshalYAML() (interface{}, error) {
+    return nil, errCustom
+}
+
+// a text (un)marshaler
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+    switch string(text) {
+    case "low":
+        *l = 1
+    case "high":
+        *l = 2
+    default:
+        return errCustom
+    }
+    return nil
+}
+
+func (l level) MarshalText() ([]byte, error) {
+    switch l {
+    case 1:
+        return []byte("low"), nil
+    case 2:
+        return []byte("high"), nil
+    }
+    return nil, errCustom
+}
+
+type converted struct {
+    Name string `yaml:"name"`
+    Size int8 `yaml:"size"`
+    Ratio float32 `yaml:"ratio,omitempty"`
+    Items []uint `yaml:"items,omitempty"`
+    Temp temperature `yaml:"temp,omitempty"`
+}
+
+func TestConverter(t *testing.T) {
+
+    when := time.Date(2001, 12, 14, 21, 59, 43, 100000000, time.UTC)
+
+    tests := []struct {
+        name string
+        src interface{}
+        into interface{}
+        expected interface{}
+        fails bool
+        err error
+    }{
+        {
+            name: "same type",
+            src: "a",
+            into: new(string),
+            expected: "a",
+        }, {
+            name: "number to string",
+            src: 2,
+            into: new(string),
+            expected: "2",
+        }, {
+            name: "number to narrower number",
+            src: 100,
+            into: new(int8),
+            expected: int8(100),
+        }, {
+            name: "number out of range",
+            src: 300,
+            into: new(int8),
+            fails: true,
+        }, {
+            name: "negative to unsigned",
+            src: -1,
+            into: new(uint),
+            fails: true,
+        }, {
+            name: "int to float",
+            src: 3,
+            into: new(float64),
+            expected: 3.0,
+        }, {
+            name: "string to bool",
+            src: "true",
+            into: new(bool),
+            expected: true,
+        }, {
+            name: "string to int",
+            src: "abc",
+            into: new(int),
+            fails: true,
+        }, {
+            name: "null",
+            src: nil,
+            into: new(int),
+            expected: 0,
+        }, {
+            name: "to pointer",
+            src: 5,
+            into: new(*int),
+            expected: func() *int { i := 5; return &i }(),
+        }, {
+            name: "generic map to struct",
+            src: map[interface{}]interface{}{
+                "name": "a", "size": 3, "ratio": 0.5, "items": []interface{}{1, 2},
+                "temp": map[interface{}]interface{}{"celsius": 21.5},
+            },
+            into: new(converted),
+            expected: converted{Name: "a", Size: 3, Ratio: 0.5, Items: []uint{1, 2}, Temp: temperature{21.5}},
+        }, {
+            name: "unknown field",
+            src: map[interface{}]interface{}{"other": 1},
+            into: new(converted),
+            fails: true,
+        }, {
+            name: "struct to generic map",
+            src: converted{Name: "a", Size: 3},
+            into: new(map[string]interface{}),
+            expected: map[string]interface{}{"name": "a", "size": int8(3), "temp": temperature{}},
+        }, {
+            name: "struct to map slice in field order",
+            src: converted{Name: "a", Size: 3},
+            into: new(MapSlice),
+            expected: MapSlice{{"name", "a"}, {"size", int8(3)}, {"temp", temperature{}}},
+        }, {
+            name: "map to map slice in natural order",
+            src: map[interface{}]int{"b": 2, 10: 10, 9: 9, true: 1},
+            into: new(MapSlice),
+            expected: MapSlice{{true, 1}, {9, 9}, {10, 10}, {"b", 2}},
+        }, {
+            name: "sequence",
+            src: []interface{}{"1", 2, 3.0},
+            into: new([]int),
+            expected: []int{1, 2, 3},
+        }, {
+            name: "sequence item out of range",
+            src: []interface{}{1, 1000},
+            into: new([]int8),
+            fails: true,
+        }, {
+            name: "sequence to short array",
+            src: []interface{}{1, 2, 3},
+            into: new([2]int),
+            fails: true,
+        }, {
+            name: "text to bytes",
+            src: "abc",
+            into: new([]byte),
+            expected: []byte("abc"),
+        }, {
+            name: "timestamp text",
+            src: "2001-12-14T21:59:43.1Z",
+            into: new(time.Time),
+            expected: when,
+        }, {
+            name: "duration text",
+            src: "1m30s",
+            into: new(time.Duration),
+            expected: 90 * time.Second,
+        }, {
+            name: "custom unmarshaler",
+            src: "abc",
+            into: new(upper),
+            expected: upper("ABC"),
+        }, {
+            name: "custom unmarshaler fallback",
+            src: map[interface{}]interface{}{"celsius": 10},
+            into: new(temperature),
+            expected: temperature{10},
+        }, {
+            name: "custom unmarshaler error",
+            src: "abc",
+            into: new(broken),
+            err: errCustom,
+        }, {
+            name: "text unmarshaler",
+            src: "high",
+            into: new(level),
+            expected: level(2),
+        }, {
+            name: "text unmarshaler error",
+            src: "medium",
+            into: new(level),
+            err: errCustom,
+        }, {
+            name: "wrapped error of a field",
+            src: map[interface{}]interface{}{"items": []interface{}{map[interface{}]interface{}{}}},
+            into: new(struct{ Items []broken `yaml:"items"` }),
+            err: errCustom,
+        }, {
+            name: "mismatch",
+            src: []interface{}{1},
+            into: new(map[string]int),
+            fails: true,
+        },
+    }
+
+    c := NewConverter(LookupSchema("core"), nil, &OptionsDefault)
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            err := c.UnmarshalFunc(reflect.ValueOf(tt.src))(tt.into)
+            if tt.err != nil {
+                if !errors.Is(err, tt.err) {
+                    t.Fatalf("expected %v, got %v", tt.err, err)
+                }
+                return
+            }
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got %#v", reflect.ValueOf(tt.into).Elem().Interface())
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            got := reflect.ValueOf(tt.into).Elem().Interface()
+            if !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %#v, expected %#v", got, tt.expected)
+            }
+        })
+    }
+}
+
+func TestConverterNonPointer(t *testing.T) {
+
+    c := NewConverter(LookupSchema("core"), nil, &OptionsDefault)
+
+    var i int
+    for _, v := range []interface{}{ i, (*int)(nil), nil } {
+        if err := c.UnmarshalFunc(reflect.ValueOf(1))(v); err == nil {
+            t.Errorf("%#v: expected an error", v)
+        }
+    }
+}
+
+func TestScalarText(t *testing.T) {
+
+    tests := []struct {
+        value interface{}
+        text string
+    }{
+        { "a", "a" },
+        { true, "true" },
+        { -3, "-3" },
+        { uint64(18446744073709551615), "18446744073709551615" },
+        { 0.5, "0.5" },
+        { float32(0.1), "0.1" },
+        { 1e100, "1e+100" },
+        { time.Minute, "1m0s" },
+    }
+
+    for _, tt := range tests {
+        text, isScalar := scalarText(reflect.ValueOf(tt.value))
+        if !isScalar || text != tt.text {
+            t.Errorf("%#v: got %q, expected %q", tt.value, text, tt.text)
+        }
+        if _, err := strconv.Unquote(strconv.Quote(text)); err != nil {
+            t.Error(err)
+        }
+    }
+
+    if _, isScalar := scalarText(reflect.ValueOf([]int{})); isScalar {
+        t.Error("a sequence is not a scalar")
+    }
+}
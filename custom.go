@@ -0,0 +1,162 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "reflect"
+    "encoding"
+)
+
+// types that marshal themselves; the returned value is marshaled instead
+type Marshaler interface {
+    MarshalYAML() (interface{}, error)
+}
+
+// types that unmarshal themselves; unmarshal decodes the node to any value
+type Unmarshaler interface {
+    UnmarshalYAML(unmarshal func(interface{}) error) error
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// the state of a value with a custom unmarshaler
+// the node is decoded to a generic value first, which is then
+// handed over to the unmarshaler; text unmarshalers get the scalar text
+type CustomState struct {
+    startRv *reflect.Value  // the value implementing the unmarshaler
+    anchor *string
+    si SchemaImplementer
+    u Unmarshaler           // the unmarshaler (if not text)
+    tu encoding.TextUnmarshaler // the text unmarshaler (if not unmarshaler)
+    ivt reflect.Value       // the generic value the node is decoded to
+    inner ObjectWrapper     // the generic state decoding the node
+}
+
+// returns nil if the value does not need a custom unmarshaler
+func NewCustomState(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+
+    opts := GetObjectOptions(path.RootUserData())
+    if !opts.Custom {
+        return nil, nil
+    }
+
+    // aliases are always resolved by the reference state
+    et := event.Type()
+    if et != Scalar && et != SequenceStart && et != MappingStart {
+        return nil, nil
+    }
+
+    // generic values never have custom unmarshalers
+    if startRv == nil || startRv.Kind() == reflect.Interface || !startRv.CanAddr() {
+        return nil, nil
+    }
+
+    // time values are handled by the schema (all the timestamp forms)
+    if startRv.Type() == timeType || startRv.Type() == durationType {
+        return nil, nil
+    }
+
+    pt := reflect.PtrTo(startRv.Type())
+
+    s := &CustomState{
+        startRv: startRv,
+        anchor: event.AnchorString(),
+        si: si,
+    }
+
+    if pt.Implements(unmarshalerType) {
+
+        s.u = startRv.Addr().Interface().(Unmarshaler)
+
+        // decode to a generic value using the schema
+        s.ivt = reflect.New(genericIfaceType).Elem()
+        ow, err := si.NewSchemaObject(event, path, &s.ivt)
+        if err != nil {
+            return nil, err
+        }
+        s.inner = ow
+
+    } else if et == Scalar && pt.Implements(textUnmarshalerType) {
+
+        s.tu = startRv.Addr().Interface().(encoding.TextUnmarshaler)
+
+    } else {
+        return nil, nil
+    }
+
+    return s, nil
+}
+
+// hand over the decoded generic value to the unmarshaler
+// the values it asks for are converted from it, not parsed again
+func (s *CustomState) unmarshal(path *Path) error {
+
+    c := NewPathConverter(path, s.si)
+
+    if err := s.u.UnmarshalYAML(c.UnmarshalFunc(s.ivt)); err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+
+    return nil
+}
+
+// the ObjectWrapper interface
+func (s *CustomState) StartRV() *reflect.Value {
+    return s.startRv
+}
+
+func (s *CustomState) Anchor() *string {
+    return s.anchor
+}
+
+func (s *CustomState) TagHandler() TagHandler {
+    if s.inner == nil {
+        return nil
+    }
+    return s.inner.TagHandler()
+}
+
+func (s *CustomState) SchemaImplementer() SchemaImplementer {
+    return s.si
+}
+
+// the CollectionWrapper interface (forwarded to the generic state)
+func (s *CustomState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, error) {
+    return s.inner.(CollectionWrapper).ObjStartIn(event, path)
+}
+
+func (s *CustomState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) error {
+    return s.inner.(CollectionWrapper).ObjEndIn(event, path, ow)
+}
+
+func (s *CustomState) CollectionStart(event *Event, path *Path) error {
+    return s.inner.(CollectionWrapper).CollectionStart(event, path)
+}
+
+func (s *CustomState) CollectionEnd(event *Event, path *Path) error {
+    if err := s.inner.(CollectionWrapper).CollectionEnd(event, path); err != nil {
+        return err
+    }
+    return s.unmarshal(path)
+}
+
+func (s *CustomState) CurrentAddress(path *Path) AddressWrapper {
+    return s.inner.(CollectionWrapper).CurrentAddress(path)
+}
+
+// the ScalarWrapper interface
+func (s *CustomState) SetScalar(event *Event, path *Path) error {
+
+    if s.tu != nil {
+        if err := s.tu.UnmarshalText([]byte(event.ScalarValue())); err != nil {
+            return fmt.Errorf("%v: %w", path, err)
+        }
+        return nil
+    }
+
+    if err := s.inner.(ScalarWrapper).SetScalar(event, path); err != nil {
+        return err
+    }
+    return s.unmarshal(path)
+}
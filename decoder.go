@@ -3,6 +3,7 @@ package fyaml
 
 import (
     "fmt"
+    "io"
     "unsafe"
 )
 
@@ -18,6 +19,29 @@ type Decoder struct {
     si SchemaImplementer
     root interface{}
     err error               // error in case of abnormal termination
+
+    p *Parser               // the parser bound to the input (if any)
+    name string             // the name of the input (for errors)
+    data unsafe.Pointer     // the C copy of the input data (if any)
+    r io.Reader             // the reader of a stream input (if any)
+    rp unsafe.Pointer       // the saved pointer given to the input callback
+    docEnd bool             // a document was completely decoded
+    streamEnd bool          // the end of the stream was reached
+    ierr error              // sticky input error, no more decoding possible
+    nread int               // the bytes read from the reader so far
+    rerr error              // a read error held back until its data is consumed
+    nodes int               // the nodes of the current document
+    pathCW CollectionWrapper // the collection at the path option (while open)
+    pathFound bool          // the node at the path option was found
+    frames []limitFrame     // the open collections of the document
+    weights map[string]int  // the nodes of the anchored subtrees
+    expanded int            // the nodes expanded by aliases
+}
+
+// an open collection for the limit checks; start is -1 if not anchored
+type limitFrame struct {
+    anchor string
+    start int
 }
 
 // just forward to the internal cmem tracker
@@ -59,15 +83,76 @@ func NewDecoder(opts...interface{}) (*Decoder, error) {
     return dec, nil
 }
 
+// create a decoder bound to in memory data
+func NewDataDecoder(data []byte, opts...interface{}) (*Decoder, error) {
+
+    dec, err := NewDecoder(opts...)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = dec.SetInputData(data); err != nil {
+        dec.Destroy()
+        return nil, err
+    }
+
+    return dec, nil
+}
+
+// create a decoder bound to a file
+func NewFileDecoder(filename string, opts...interface{}) (*Decoder, error) {
+
+    dec, err := NewDecoder(opts...)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = dec.SetInputFile(filename); err != nil {
+        dec.Destroy()
+        return nil, err
+    }
+
+    return dec, nil
+}
+
+// create a decoder bound to a reader; the input is read incrementally
+func NewStreamDecoder(r io.Reader, opts...interface{}) (*Decoder, error) {
+
+    dec, err := NewDecoder(opts...)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = dec.SetInputReader(r); err != nil {
+        dec.Destroy()
+        return nil, err
+    }
+
+    return dec, nil
+}
+
 func (dec *Decoder) Destroy() {
     if dec == nil {
         return
     }
 
+    // release the input
+    dec.inputReset()
+
     // and the tracker
     dec.cmt.Destroy()
 }
 
+// implement the OptionsProvider interface
+func (dec *Decoder) Options() *Options {
+    return dec.opts
+}
+
+// implement the StructTagsProvider interface
+func (dec *Decoder) StructTags() []string {
+    return dec.opts.StructTags()
+}
+
 func (dec *Decoder) Debugf(format string, a ...interface{}) {
     if dec.opts.Debug {
         print(fmt.Sprintf(format, a...))
@@ -81,3 +166,39 @@ func (dec *Decoder) SetError(err error) {
 func (dec *Decoder) Error() error {
     return dec.err
 }
+
+// implement the InputReader interface
+func (dec *Decoder) ReadInput(buf []byte) (int, error) {
+    if dec.r == nil {
+        return 0, io.EOF
+    }
+
+    // report the error that came along with the last data
+    if dec.rerr != nil {
+        err := dec.rerr
+        dec.rerr = nil
+        return 0, err
+    }
+
+    // over the limit, stop reading
+    max := dec.opts.MaxInputBytes
+    if max > 0 && dec.nread > max {
+        return 0, limitError("maximum input bytes", max)
+    }
+
+    // a byte over the limit is enough to tell
+    if max > 0 && len(buf) > max - dec.nread + 1 {
+        buf = buf[:max - dec.nread + 1]
+    }
+
+    n, err := dec.r.Read(buf)
+    dec.nread += n
+
+    // the data goes first, the error on the next call
+    if n > 0 && err != nil {
+        dec.rerr = err
+        err = nil
+    }
+
+    return n, err
+}
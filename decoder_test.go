@@ -0,0 +1,441 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "io"
+    "errors"
+    "strings"
+    "reflect"
+    "testing"
+    "testing/iotest"
+)
+
+// decode all the documents of a decoder to generic values
+func decodeAll(dec *Decoder) ([]interface{}, error) {
+    var docs []interface{}
+    for {
+        var v interface{}
+        err := dec.Decode(&v)
+        if err == io.EOF {
+            return docs, nil
+        }
+        if err != nil {
+            return docs, err
+        }
+        docs = append(docs, v)
+    }
+}
+
+func TestDecodeStream(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        docs []interface{}
+        fails bool
+    }{
+        {
+            name: "single",
+            input: "a: 1\n",
+            docs: []interface{}{ map[interface{}]interface{}{"a": 1} },
+        }, {
+            name: "multiple",
+            input: "--- 1\n--- two\n--- [3]\n",
+            docs: []interface{}{ 1, "two", []interface{}{3} },
+        }, {
+            name: "explicit ends",
+            input: "a\n...\n---\nb\n...\n",
+            docs: []interface{}{ "a", "b" },
+        }, {
+            name: "empty document",
+            input: "---\n--- x\n",
+            docs: []interface{}{ nil, "x" },
+        }, {
+            name: "empty stream",
+            input: "",
+            docs: nil,
+        }, {
+            name: "error in second document",
+            input: "--- ok\n--- [unterminated\n",
+            docs: []interface{}{ "ok" },
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            dec, err := NewDataDecoder([]byte(tt.input))
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer dec.Destroy()
+
+            docs, err := decodeAll(dec)
+            if tt.fails != (err != nil) {
+                t.Fatalf("unexpected error state: %v", err)
+            }
+            if !reflect.DeepEqual(docs, tt.docs) {
+                t.Errorf("got %#v, expected %#v", docs, tt.docs)
+            }
+        })
+    }
+}
+
+func TestDecodeAfterEnd(t *testing.T) {
+
+    dec, err := NewDataDecoder([]byte("--- 1\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer dec.Destroy()
+
+    var v int
+    if err := dec.Decode(&v); err != nil || v != 1 {
+        t.Fatalf("got %v, %v", v, err)
+    }
+
+    // the end is sticky
+    for i := 0; i < 2; i++ {
+        if err := dec.Decode(&v); err != io.EOF {
+            t.Fatalf("expected io.EOF, got %v", err)
+        }
+    }
+}
+
+func TestDecodeErrorIsSticky(t *testing.T) {
+
+    dec, err := NewDataDecoder([]byte("--- [\n--- 2\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer dec.Destroy()
+
+    var v interface{}
+    err1 := dec.Decode(&v)
+    if err1 == nil {
+        t.Fatal("expected a parse error")
+    }
+    if err2 := dec.Decode(&v); err2 != err1 {
+        t.Errorf("expected the same error, got %v", err2)
+    }
+}
+
+func TestDecodeUnbound(t *testing.T) {
+
+    dec, err := NewDecoder()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer dec.Destroy()
+
+    var v interface{}
+    if err := dec.Decode(&v); err == nil || err == io.EOF {
+        t.Errorf("expected an error, got %v", err)
+    }
+}
+
+var errReader = errors.New("reader failure")
+
+// returns the data along with the error
+type dataErrReader struct {
+    data string
+    err error
+}
+
+func (r *dataErrReader) Read(buf []byte) (int, error) {
+    if r.data == "" {
+        return 0, r.err
+    }
+    n := copy(buf, r.data)
+    r.data = r.data[n:]
+    if r.data == "" {
+        return n, r.err
+    }
+    return n, nil
+}
+
+// never returns anything
+type stuckReader struct {
+}
+
+func (r *stuckReader) Read(buf []byte) (int, error) {
+    return 0, nil
+}
+
+func TestStreamDecoderReader(t *testing.T) {
+
+    const input = "--- 1\n--- [a, b]\n--- {c: d}\n"
+    expected := []interface{}{ 1, []interface{}{"a", "b"}, map[interface{}]interface{}{"c": "d"} }
+
+    tests := []struct {
+        name string
+        r io.Reader
+        docs []interface{}
+        err error
+    }{
+        {
+            name: "plain",
+            r: strings.NewReader(input),
+            docs: expected,
+        }, {
+            name: "one byte at a time",
+            r: iotest.OneByteReader(strings.NewReader(input)),
+            docs: expected,
+        }, {
+            name: "eof with the data",
+            r: iotest.DataErrReader(strings.NewReader(input)),
+            docs: expected,
+        }, {
+            name: "half reads",
+            r: iotest.HalfReader(strings.NewReader(input)),
+            docs: expected,
+        }, {
+            name: "error with the data",
+            r: &dataErrReader{data: input, err: errReader},
+            docs: expected,
+            err: errReader,
+        }, {
+            name: "error only",
+            r: iotest.ErrReader(errReader),
+            err: errReader,
+        }, {
+            name: "no progress",
+            r: &stuckReader{},
+            err: io.ErrNoProgress,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            dec, err := NewStreamDecoder(tt.r)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer dec.Destroy()
+
+            docs, err := decodeAll(dec)
+            if tt.err == nil && err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if tt.err != nil && !errors.Is(err, tt.err) {
+                t.Fatalf("expected %v, got %v", tt.err, err)
+            }
+
+            // the documents before the error are decoded; the last one
+            // might need the read that fails to end
+            expected := tt.docs
+            if tt.err != nil && len(docs) <= len(expected) && len(expected) - len(docs) <= 1 {
+                expected = expected[:len(docs)]
+            }
+            if !reflect.DeepEqual(docs, expected) {
+                t.Errorf("got %#v, expected %#v", docs, tt.docs)
+            }
+        })
+    }
+}
+
+func TestReadInputHoldsError(t *testing.T) {
+
+    dec := &Decoder{
+        opts: &Options{},
+        r: &dataErrReader{data: "abc", err: errReader},
+    }
+
+    buf := make([]byte, 16)
+
+    n, err := dec.ReadInput(buf)
+    if n != 3 || err != nil {
+        t.Fatalf("got %d, %v; expected the data first", n, err)
+    }
+
+    n, err = dec.ReadInput(buf)
+    if n != 0 || err != errReader {
+        t.Fatalf("got %d, %v; expected the held error", n, err)
+    }
+}
+
+func TestStreamDecoderNilReader(t *testing.T) {
+    if _, err := NewStreamDecoder(nil); err == nil {
+        t.Error("expected an error for a nil reader")
+    }
+}
+
+const laughs = `
+a: &a [x, x, x, x, x, x, x, x, x, x]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]
+`
+
+func TestDecodeLimits(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        opts []interface{}
+        fails bool
+    }{
+        {
+            name: "expansion within the limit",
+            input: laughs,
+            opts: []interface{}{"max-alias-expansion=2000"},
+        }, {
+            name: "expansion over the limit",
+            input: laughs,
+            opts: []interface{}{"max-alias-expansion=1000"},
+            fails: true,
+        }, {
+            name: "aliases count as nodes",
+            input: laughs,
+            opts: []interface{}{"max-nodes=1000"},
+            fails: true,
+        }, {
+            name: "nodes within the limit",
+            input: "[1, 2, 3]\n",
+            opts: []interface{}{"max-nodes=4"},
+        }, {
+            name: "nodes over the limit",
+            input: "[1, 2, 3]\n",
+            opts: []interface{}{"max-nodes=3"},
+            fails: true,
+        }, {
+            name: "keys are nodes",
+            input: "{a: 1, b: 2}\n",
+            opts: []interface{}{"max-nodes=4"},
+            fails: true,
+        }, {
+            name: "depth within the limit",
+            input: "[[[1]]]\n",
+            opts: []interface{}{"max-depth=10"},
+        }, {
+            name: "depth over the limit",
+            input: "a: [[[[[1]]]]]\n",
+            opts: []interface{}{"max-depth=2"},
+            fails: true,
+        }, {
+            name: "input within the limit",
+            input: "a: 1\n",
+            opts: []interface{}{"max-input-bytes=5"},
+        }, {
+            name: "input over the limit",
+            input: "a: 1\n",
+            opts: []interface{}{"max-input-bytes=4"},
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            var v interface{}
+            err := Unmarshal([]byte(tt.input), &v, tt.opts...)
+            if tt.fails {
+                if !errors.Is(err, ErrLimitExceeded) {
+                    t.Fatalf("expected %v, got %v", ErrLimitExceeded, err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+        })
+    }
+}
+
+func TestStreamDecoderLimits(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        opts []interface{}
+        docs int
+    }{
+        {
+            name: "input over the limit",
+            input: "--- a\n--- b\n--- c\n",
+            opts: []interface{}{"max-input-bytes=8"},
+            docs: 1,
+        }, {
+            name: "nodes per document",
+            input: "--- [1, 2]\n--- [1, 2, 3]\n",
+            opts: []interface{}{"max-nodes=3"},
+            docs: 1,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            dec, err := NewStreamDecoder(strings.NewReader(tt.input), tt.opts...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer dec.Destroy()
+
+            docs, err := decodeAll(dec)
+            if !errors.Is(err, ErrLimitExceeded) {
+                t.Fatalf("expected %v, got %v", ErrLimitExceeded, err)
+            }
+            if len(docs) > tt.docs {
+                t.Errorf("got %d documents, expected at most %d", len(docs), tt.docs)
+            }
+        })
+    }
+}
+
+func TestReadInputLimit(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        fails bool
+    }{
+        { name: "at the limit", input: "0123" },
+        { name: "over the limit", input: "0123456789", fails: true },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            dec := &Decoder{
+                opts: &Options{MaxInputBytes: 4},
+                r: strings.NewReader(tt.input),
+            }
+
+            buf := make([]byte, 64)
+            total := 0
+            for {
+                n, err := dec.ReadInput(buf)
+                total += n
+                // never reads far beyond the limit
+                if total > 5 {
+                    t.Fatalf("read %d bytes", total)
+                }
+                if err == io.EOF {
+                    if tt.fails {
+                        t.Fatal("expected a limit error")
+                    }
+                    return
+                }
+                if err != nil {
+                    if !tt.fails || !errors.Is(err, ErrLimitExceeded) {
+                        t.Fatalf("unexpected error %v", err)
+                    }
+                    return
+                }
+            }
+        })
+    }
+}
+
+func TestLimitError(t *testing.T) {
+
+    err := limitError("maximum nodes", 10)
+    if !errors.Is(err, ErrLimitExceeded) {
+        t.Errorf("%v does not wrap %v", err, ErrLimitExceeded)
+    }
+    if !strings.Contains(err.Error(), "maximum nodes 10") {
+        t.Errorf("unexpected message %q", err)
+    }
+}
@@ -0,0 +1,396 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "unsafe"
+    "errors"
+)
+
+/*
+#cgo pkg-config: libfyaml
+#include "callback.h"
+*/
+import "C"
+
+// create a document using the parse configuration of the options;
+// build is called with the configuration and returns the document
+func documentBuild(name string, build func(cfg *ParseCfg) *C.struct_fy_document, opts...interface{}) (*Document, error) {
+
+    // the configuration is only needed while building
+    cmt := CMemTrackerCreate()
+    defer cmt.Destroy()
+
+    cfg, err := ParseCfgCreate(cmt, opts...)
+    if err != nil {
+        return nil, err
+    }
+    // the document keeps its own diag reference
+    defer cfg.Destroy(cmt)
+
+    // the document copies the configuration; no dangling search path
+    cmt.Free(unsafe.Pointer(cfg.search_path))
+    cfg.search_path = nil
+
+    d := (*Document)(build(cfg))
+    if d == nil {
+        if dl := (*Diag)(cfg.diag).Diagnostics(name); len(dl) > 0 {
+            return nil, dl
+        }
+        return nil, errors.New(fmt.Sprintf("Failed to build document from %s", name))
+    }
+
+    return d, nil
+}
+
+// create an empty document
+func DocumentCreate(opts...interface{}) (*Document, error) {
+    return documentBuild("<empty>", func(cfg *ParseCfg) *C.struct_fy_document {
+        return C.fy_document_create(cfg.C())
+    }, opts...)
+}
+
+// parse the (first) document of in memory data
+func ParseDocument(data []byte, opts...interface{}) (*Document, error) {
+    return documentBuild("<data>", func(cfg *ParseCfg) *C.struct_fy_document {
+        // the document takes ownership of the malloc'ed copy;
+        // on failure the parser cleanup frees it, so no free here
+        size := len(data)
+        str := (*C.char)(C.malloc(C.size_t(size + 1)))
+        copy(unsafe.Slice((*byte)(unsafe.Pointer(str)), size), data)
+        return C.fy_document_build_from_malloc_string(cfg.C(), str, C.size_t(size))
+    }, opts...)
+}
+
+// parse the (first) document of a file
+func ParseDocumentFile(filename string, opts...interface{}) (*Document, error) {
+    return documentBuild(filename, func(cfg *ParseCfg) *C.struct_fy_document {
+        cfile := C.CString(filename)
+        defer C.free(unsafe.Pointer(cfile))
+        return C.fy_document_build_from_file(cfg.C(), cfile)
+    }, opts...)
+}
+
+func (d *Document) Destroy() {
+    if d == nil {
+        return
+    }
+
+    // the tag memory kept by the document goes with it
+    C.fy_document_destroy(d.C())
+}
+
+func (d *Document) Root() *Node {
+    return (*Node)(C.fy_document_root(d.C()))
+}
+
+func (d *Document) SetRoot(n *Node) error {
+    if rc := C.fy_document_set_root(d.C(), n.C()); rc != 0 {
+        return errors.New("failed to set document root")
+    }
+    return nil
+}
+
+// emit the document using the emitter options
+func (d *Document) Emit(opts...interface{}) ([]byte, error) {
+
+    o, err := GetOptions(opts)
+    if err != nil {
+        return nil, err
+    }
+
+    cstr := C.fy_emit_document_to_string(d.C(), emitterCfgFlagsFromOptions(o))
+    if cstr == nil {
+        return nil, errors.New("failed to emit document")
+    }
+    defer C.free(unsafe.Pointer(cstr))
+
+    return []byte(C.GoString(cstr)), nil
+}
+
+func (d *Document) String() string {
+    out, err := d.Emit()
+    if err != nil {
+        return ""
+    }
+    return string(out)
+}
+
+// the created nodes are not attached to the tree;
+// attach them with SetRoot, SequenceAppend or MappingAppend (or Free them)
+func (d *Document) CreateScalar(value string) (*Node, error) {
+    cstr := C.CString(value)
+    defer C.free(unsafe.Pointer(cstr))
+
+    n := (*Node)(C.fy_node_create_scalar_copy(d.C(), cstr, C.size_t(len(value))))
+    if n == nil {
+        return nil, errors.New("failed to create scalar node")
+    }
+    return n, nil
+}
+
+func (d *Document) CreateSequence() (*Node, error) {
+    n := (*Node)(C.fy_node_create_sequence(d.C()))
+    if n == nil {
+        return nil, errors.New("failed to create sequence node")
+    }
+    return n, nil
+}
+
+func (d *Document) CreateMapping() (*Node, error) {
+    n := (*Node)(C.fy_node_create_mapping(d.C()))
+    if n == nil {
+        return nil, errors.New("failed to create mapping node")
+    }
+    return n, nil
+}
+
+type NodeKind C.enum_fy_node_type
+
+const (
+    ScalarNode NodeKind     = C.FYNT_SCALAR
+    SequenceNode NodeKind   = C.FYNT_SEQUENCE
+    MappingNode NodeKind    = C.FYNT_MAPPING
+)
+
+func (nk NodeKind) String() string {
+    switch nk {
+    case ScalarNode:
+        return "scalar"
+    case SequenceNode:
+        return "sequence"
+    case MappingNode:
+        return "mapping"
+    }
+    return ""
+}
+
+type Node C.struct_fy_node
+
+func (n *Node) C() *C.struct_fy_node {
+    return (*C.struct_fy_node)(n)
+}
+
+func (n *Node) Document() *Document {
+    return (*Document)(C.fy_node_document(n.C()))
+}
+
+func (n *Node) Kind() NodeKind {
+    return NodeKind(C.fy_node_get_type(n.C()))
+}
+
+func (n *Node) IsScalar() bool {
+    return n.Kind() == ScalarNode
+}
+
+func (n *Node) IsSequence() bool {
+    return n.Kind() == SequenceNode
+}
+
+func (n *Node) IsMapping() bool {
+    return n.Kind() == MappingNode
+}
+
+func (n *Node) IsAlias() bool {
+    return bool(C.fy_node_is_alias(n.C()))
+}
+
+func (n *Node) Style() NodeStyle {
+    return NodeStyle(C.fy_node_get_style(n.C()))
+}
+
+// the tag of the node ("" if none)
+func (n *Node) Tag() string {
+    var size C.size_t
+    cstr := C.fy_node_get_tag(n.C(), &size)
+    if cstr == nil {
+        return ""
+    }
+    return C.GoStringN(cstr, C.int(size))
+}
+
+// the anchor of the node ("" if none)
+func (n *Node) Anchor() string {
+    fya := C.fy_node_get_anchor(n.C())
+    if fya == nil {
+        return ""
+    }
+    var size C.size_t
+    cstr := C.fy_anchor_get_text(fya, &size)
+    if cstr == nil {
+        return ""
+    }
+    return C.GoStringN(cstr, C.int(size))
+}
+
+// the value of a scalar (or the anchor name of an alias)
+func (n *Node) ScalarValue() string {
+    var size C.size_t
+    cstr := C.fy_node_get_scalar(n.C(), &size)
+    if cstr == nil {
+        return ""
+    }
+    return C.GoStringN(cstr, C.int(size))
+}
+
+func (n *Node) ScalarToken() *Token {
+    return (*Token)(C.fy_node_get_scalar_token(n.C()))
+}
+
+func (n *Node) Parent() *Node {
+    return (*Node)(C.fy_node_get_parent(n.C()))
+}
+
+// the path of the node from the root of the document
+func (n *Node) Path() string {
+    cstr := C.fy_node_get_path(n.C())
+    if cstr == nil {
+        return ""
+    }
+    defer C.free(unsafe.Pointer(cstr))
+
+    return C.GoString(cstr)
+}
+
+func (n *Node) SetTag(tag string) error {
+    ctag := C.CString(tag)
+    defer C.free(unsafe.Pointer(ctag))
+
+    // the tag text must outlive the node, so the document keeps it
+    cstr := C.document_keep_string(n.Document().C(), ctag, C.size_t(len(tag)))
+    if cstr == nil {
+        return errors.New(fmt.Sprintf("failed to allocate tag %s", tag))
+    }
+    if rc := C.fy_node_set_tag(n.C(), cstr, C.size_t(len(tag))); rc != 0 {
+        return errors.New(fmt.Sprintf("failed to set tag %s", tag))
+    }
+    return nil
+}
+
+func (n *Node) SetAnchor(anchor string) error {
+    cstr := C.CString(anchor)
+    defer C.free(unsafe.Pointer(cstr))
+
+    if rc := C.fy_node_set_anchor_copy(n.C(), cstr, C.size_t(len(anchor))); rc != 0 {
+        return errors.New(fmt.Sprintf("failed to set anchor %s", anchor))
+    }
+    return nil
+}
+
+func (n *Node) SetStyle(ns NodeStyle) error {
+    if rc := C.fy_node_set_style(n.C(), ns.C()); rc != 0 {
+        return errors.New(fmt.Sprintf("failed to set style %s", ns))
+    }
+    return nil
+}
+
+// free a node that is not attached to the tree
+func (n *Node) Free() {
+    C.fy_node_free(n.C())
+}
+
+// emit the node (and its children) using the emitter options
+func (n *Node) Emit(opts...interface{}) ([]byte, error) {
+
+    o, err := GetOptions(opts)
+    if err != nil {
+        return nil, err
+    }
+
+    cstr := C.fy_emit_node_to_string(n.C(), emitterCfgFlagsFromOptions(o))
+    if cstr == nil {
+        return nil, errors.New("failed to emit node")
+    }
+    defer C.free(unsafe.Pointer(cstr))
+
+    return []byte(C.GoString(cstr)), nil
+}
+
+func (n *Node) String() string {
+    out, err := n.Emit()
+    if err != nil {
+        return ""
+    }
+    return string(out)
+}
+
+func (n *Node) SequenceLen() int {
+    return int(C.fy_node_sequence_item_count(n.C()))
+}
+
+// the items of a sequence in order
+func (n *Node) SequenceItems() []*Node {
+
+    var items []*Node = nil
+    var prevp unsafe.Pointer = nil
+
+    for {
+        fyn := C.fy_node_sequence_iterate(n.C(), &prevp)
+        if fyn == nil {
+            break
+        }
+        items = append(items, (*Node)(fyn))
+    }
+    return items
+}
+
+// negative indices count from the end
+func (n *Node) SequenceItem(index int) *Node {
+    return (*Node)(C.fy_node_sequence_get_by_index(n.C(), C.int(index)))
+}
+
+func (n *Node) SequenceAppend(item *Node) error {
+    if rc := C.fy_node_sequence_append(n.C(), item.C()); rc != 0 {
+        return errors.New("failed to append to sequence")
+    }
+    return nil
+}
+
+type NodePair C.struct_fy_node_pair
+
+func (np *NodePair) C() *C.struct_fy_node_pair {
+    return (*C.struct_fy_node_pair)(np)
+}
+
+func (np *NodePair) Key() *Node {
+    return (*Node)(C.fy_node_pair_key(np.C()))
+}
+
+func (np *NodePair) Value() *Node {
+    return (*Node)(C.fy_node_pair_value(np.C()))
+}
+
+func (n *Node) MappingLen() int {
+    return int(C.fy_node_mapping_item_count(n.C()))
+}
+
+// the key/value pairs of a mapping in document order
+func (n *Node) MappingPairs() []*NodePair {
+
+    var pairs []*NodePair = nil
+    var prevp unsafe.Pointer = nil
+
+    for {
+        fynp := C.fy_node_mapping_iterate(n.C(), &prevp)
+        if fynp == nil {
+            break
+        }
+        pairs = append(pairs, (*NodePair)(fynp))
+    }
+    return pairs
+}
+
+// the value of a (simple) key of a mapping, nil if not found
+func (n *Node) MappingLookup(key string) *Node {
+    cstr := C.CString(key)
+    defer C.free(unsafe.Pointer(cstr))
+
+    return (*Node)(C.fy_node_mapping_lookup_by_string(n.C(), cstr, C.size_t(len(key))))
+}
+
+func (n *Node) MappingAppend(key *Node, value *Node) error {
+    if rc := C.fy_node_mapping_append(n.C(), key.C(), value.C()); rc != 0 {
+        return errors.New("failed to append to mapping")
+    }
+    return nil
+}
@@ -0,0 +1,242 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseDocument(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        kind NodeKind
+        expected interface{}
+        fails bool
+    }{
+        {
+            name: "mapping",
+            input: "a: 1\nb: [x, y]\n",
+            kind: MappingNode,
+            expected: map[interface{}]interface{}{"a": 1, "b": []interface{}{"x", "y"}},
+        }, {
+            name: "sequence",
+            input: "- 1\n- two\n",
+            kind: SequenceNode,
+            expected: []interface{}{1, "two"},
+        }, {
+            name: "scalar",
+            input: "hello\n",
+            kind: ScalarNode,
+            expected: "hello",
+        }, {
+            name: "only the first document",
+            input: "--- 1\n--- 2\n",
+            kind: ScalarNode,
+            expected: 1,
+        }, {
+            name: "unterminated flow sequence",
+            input: "a: [1, 2\n",
+            fails: true,
+        }, {
+            name: "bad indentation",
+            input: "a:\n  b: 1\n c: 2\n",
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            d, err := ParseDocument([]byte(tt.input))
+            if tt.fails {
+                if err == nil {
+                    d.Destroy()
+                    t.Fatal("expected an error")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer d.Destroy()
+
+            root := d.Root()
+            if root == nil {
+                t.Fatal("no root node")
+            }
+            if root.Kind() != tt.kind {
+                t.Errorf("got a %s root, expected a %s", root.Kind(), tt.kind)
+            }
+
+            // the emitted document decodes to the same value
+            out, err := d.Emit()
+            if err != nil {
+                t.Fatal(err)
+            }
+            var got interface{}
+            if err := Unmarshal(out, &got); err != nil {
+                t.Fatalf("%v in:\n%s", err, out)
+            }
+            if !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %#v, expected %#v from:\n%s", got, tt.expected, out)
+            }
+        })
+    }
+}
+
+func TestDocumentCreate(t *testing.T) {
+
+    d, err := DocumentCreate()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer d.Destroy()
+
+    root, err := d.CreateMapping()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := d.SetRoot(root); err != nil {
+        t.Fatal(err)
+    }
+
+    seq, err := d.CreateSequence()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, v := range []string{"x", "y"} {
+        item, err := d.CreateScalar(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := seq.SequenceAppend(item); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    key, err := d.CreateScalar("list")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := root.MappingAppend(key, seq); err != nil {
+        t.Fatal(err)
+    }
+
+    // the tag is built in a temporary string; the document keeps a copy
+    key, err = d.CreateScalar("count")
+    if err != nil {
+        t.Fatal(err)
+    }
+    value, err := d.CreateScalar("12")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := value.SetTag(string([]byte("!!str"))); err != nil {
+        t.Fatal(err)
+    }
+    if err := root.MappingAppend(key, value); err != nil {
+        t.Fatal(err)
+    }
+
+    // either the short or the resolved form
+    if tag := root.MappingLookup("count").Tag(); !strings.HasSuffix(tag, "str") {
+        t.Errorf("got tag %q, expected !!str", tag)
+    }
+    if n := root.MappingLookup("list").SequenceLen(); n != 2 {
+        t.Errorf("got %d items, expected 2", n)
+    }
+
+    out, err := d.Emit()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var got interface{}
+    if err := Unmarshal(out, &got); err != nil {
+        t.Fatalf("%v in:\n%s", err, out)
+    }
+    expected := map[interface{}]interface{}{"list": []interface{}{"x", "y"}, "count": "12"}
+    if !reflect.DeepEqual(got, expected) {
+        t.Errorf("got %#v, expected %#v from:\n%s", got, expected, out)
+    }
+}
+
+func TestDocumentNodes(t *testing.T) {
+
+    d, err := ParseDocument([]byte("a: &x !!str 1\nb: *x\nc: [1, 2, 3]\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer d.Destroy()
+
+    root := d.Root()
+
+    tests := []struct {
+        key string
+        value string
+        tag string
+        anchor string
+        alias bool
+    }{
+        { key: "a", value: "1", tag: "str", anchor: "x" },
+        { key: "b", value: "x", alias: true },
+    }
+
+    for _, tt := range tests {
+        n := root.MappingLookup(tt.key)
+        if n == nil {
+            t.Fatalf("%s: not found", tt.key)
+        }
+        if v := n.ScalarValue(); v != tt.value {
+            t.Errorf("%s: got value %q, expected %q", tt.key, v, tt.value)
+        }
+        if tag := n.Tag(); !strings.HasSuffix(tag, tt.tag) || (tag == "") != (tt.tag == "") {
+            t.Errorf("%s: got tag %q, expected %q", tt.key, tag, tt.tag)
+        }
+        if anchor := n.Anchor(); anchor != tt.anchor {
+            t.Errorf("%s: got anchor %q, expected %q", tt.key, anchor, tt.anchor)
+        }
+        if n.IsAlias() != tt.alias {
+            t.Errorf("%s: got alias %v, expected %v", tt.key, n.IsAlias(), tt.alias)
+        }
+    }
+
+    c := root.MappingLookup("c")
+    if c.SequenceLen() != 3 || c.SequenceItem(1).ScalarValue() != "2" {
+        t.Errorf("unexpected sequence %s", c)
+    }
+    if p := c.SequenceItem(2).Path(); p != "/c/2" {
+        t.Errorf("got path %q, expected %q", p, "/c/2")
+    }
+    if c.SequenceItem(0).Parent().C() != c.C() {
+        t.Error("wrong parent")
+    }
+    if root.MappingLookup("none") != nil {
+        t.Error("found a missing key")
+    }
+}
+
+func TestDocumentDestroyNil(t *testing.T) {
+    var d *Document
+    d.Destroy()
+}
+
+// a failed parse releases the input once; repeat to catch double frees
+// (run with GODEBUG=cgocheck=1 or an ASan built libfyaml)
+func TestParseDocumentFailureRepeated(t *testing.T) {
+
+    inputs := []string{"a: [1, 2\n", "a:\n  b: 1\n c: 2\n", "{a: 1\n", "- a\nb: c\n"}
+
+    for i := 0; i < 100; i++ {
+        for _, input := range inputs {
+            d, err := ParseDocument([]byte(input))
+            if err == nil {
+                d.Destroy()
+                t.Fatalf("%q: expected an error", input)
+            }
+        }
+    }
+}
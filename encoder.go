@@ -3,8 +3,9 @@ package fyaml
 
 import (
     "fmt"
+    "io"
+    "errors"
     "unsafe"
-    // "errors"
     // gopointer "github.com/mattn/go-pointer"
 )
 
@@ -22,6 +23,18 @@ type Encoder struct {
     root interface{}
     err error               // error in case of abnormal termination
     jsonOutput bool         // is the output json
+
+    w io.Writer             // the writer of a stream output (if any)
+    e *Emitter              // the emitter bound to the writer (if any)
+    docs int                // number of documents encoded in the stream
+    oerr error              // sticky output error, the stream can't be continued
+
+    refs map[refKey]int         // the references to shared values of the document
+    anchors map[refKey]string   // the anchors of the shared values emitted
+    visiting map[refKey]bool    // the values being emitted (when not aliasing)
+    anchorCount int             // the last anchor number used
+    anchor string               // the anchor of the next node emitted
+    pending []refKey            // the shared values the pending anchor names
 }
 
 // just forward to the internal cmem tracker
@@ -61,6 +74,56 @@ func NewEncoder(opts...interface{}) (*Encoder, error) {
 
     enc.sc = NewStructCache(enc)
     enc.jsonOutput = o.OutputMode == "json" || o.OutputMode == "json-oneline"
+    enc.si = encoderSchema(o, enc.jsonOutput)
+
+    return enc, nil
+}
+
+// the schema the output is resolved with when read back
+func encoderSchema(o *Options, jsonOutput bool) SchemaImplementer {
+
+    name := o.Schema
+    if name == "auto" {
+        switch {
+        case jsonOutput:
+            name = "json"
+        case o.Version == "1.1" || o.Version == "1.3":
+            name = o.Version
+        default:
+            name = "core"
+        }
+    }
+
+    return LookupSchema(name)
+}
+
+// create an encoder writing a stream of documents to w
+// call Close() to end the stream
+func NewStreamEncoder(w io.Writer, opts...interface{}) (*Encoder, error) {
+
+    if w == nil {
+        return nil, errors.New("nil writer for output")
+    }
+
+    enc, err := NewEncoder(opts...)
+    if err != nil {
+        return nil, err
+    }
+
+    enc.w = w
+
+    // the emitter output goes through the encoder (an OutputWriter)
+    enc.e, err = EmitterCreate(enc, enc.opts)
+    if err != nil {
+        enc.Destroy()
+        return nil, err
+    }
+
+    // stream start
+    if err = enc.emitEvent(StreamStart); err != nil {
+        enc.Destroy()
+        return nil, err
+    }
 
     return enc, nil
 }
@@ -70,13 +133,60 @@ func (enc *Encoder) Destroy() {
         return
     }
 
+    // drop the stream emitter (if not closed)
+    if enc.e != nil {
+        enc.e.Destroy()
+        enc.e = nil
+    }
+
     // and the tracker
     enc.cmt.Destroy()
 }
 
+// implement the OptionsProvider interface
+func (enc *Encoder) Options() *Options {
+    return enc.opts
+}
+
+// implement the StructTagsProvider interface
+func (enc *Encoder) StructTags() []string {
+    return enc.opts.StructTags()
+}
+
 func (enc *Encoder) Debugf(format string, a ...interface{}) {
     if enc.opts.Debug {
         print(fmt.Sprintf(format, a...))
     }
 }
 
+func (enc *Encoder) SetError(err error) {
+    enc.err = err
+}
+
+func (enc *Encoder) Error() error {
+    return enc.err
+}
+
+// implement the OutputWriter interface
+func (enc *Encoder) WriteOutput(buf []byte) (int, error) {
+    if enc.w == nil {
+        return 0, errors.New("no writer for output")
+    }
+    return enc.w.Write(buf)
+}
+
+// emit an event on the stream emitter, reporting write errors first
+func (enc *Encoder) emitEvent(etype EventType, args...interface{}) error {
+
+    enc.err = nil
+
+    err := enc.e.EmitEvent(etype, args...)
+
+    // the writer error is the real cause
+    if enc.err != nil {
+        err = enc.err
+        enc.err = nil
+    }
+
+    return err
+}
@@ -0,0 +1,150 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "bytes"
+    "errors"
+    "reflect"
+    "testing"
+)
+
+var errWriter = errors.New("writer failure")
+
+// fails after writing a number of bytes
+type failingWriter struct {
+    left int
+}
+
+func (w *failingWriter) Write(buf []byte) (int, error) {
+    if len(buf) > w.left {
+        n := w.left
+        w.left = 0
+        return n, errWriter
+    }
+    w.left -= len(buf)
+    return len(buf), nil
+}
+
+func TestStreamEncoder(t *testing.T) {
+
+    tests := []struct {
+        name string
+        docs []interface{}
+    }{
+        {
+            name: "single",
+            docs: []interface{}{ map[interface{}]interface{}{"a": 1} },
+        }, {
+            name: "multiple",
+            docs: []interface{}{ 1, "two", []interface{}{3, 4} },
+        }, {
+            name: "mappings",
+            docs: []interface{}{
+                map[interface{}]interface{}{"a": 1},
+                map[interface{}]interface{}{"b": []interface{}{"c"}},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            var buf bytes.Buffer
+
+            enc, err := NewStreamEncoder(&buf)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer enc.Destroy()
+
+            for _, doc := range tt.docs {
+                if err := enc.Encode(doc); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            if err := enc.Close(); err != nil {
+                t.Fatal(err)
+            }
+
+            // and back
+            dec, err := NewDataDecoder(buf.Bytes())
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer dec.Destroy()
+
+            docs, err := decodeAll(dec)
+            if err != nil {
+                t.Fatalf("%v in:\n%s", err, buf.String())
+            }
+            if !reflect.DeepEqual(docs, tt.docs) {
+                t.Errorf("got %#v, expected %#v from:\n%s", docs, tt.docs, buf.String())
+            }
+        })
+    }
+}
+
+func TestStreamEncoderErrorsAreSticky(t *testing.T) {
+
+    tests := []struct {
+        name string
+        w *failingWriter
+        docs []interface{}
+        err error
+    }{
+        {
+            name: "writer failure",
+            w: &failingWriter{left: 8},
+            docs: []interface{}{ "a long enough first document", "second", "third" },
+            err: errWriter,
+        }, {
+            name: "unsupported value",
+            w: &failingWriter{left: 1 << 20},
+            docs: []interface{}{ "first", make(chan int), "third" },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            enc, err := NewStreamEncoder(tt.w)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer enc.Destroy()
+
+            var first error
+            for _, doc := range tt.docs {
+                err := enc.Encode(doc)
+                if first == nil {
+                    first = err
+                    continue
+                }
+                // once failed, always failed
+                if err != first {
+                    t.Fatalf("expected %v, got %v", first, err)
+                }
+            }
+
+            cerr := enc.Close()
+            if first == nil {
+                first = cerr
+            } else if cerr != first {
+                t.Fatalf("expected %v from Close, got %v", first, cerr)
+            }
+
+            if first == nil {
+                t.Fatal("expected an error")
+            }
+            if tt.err != nil && !errors.Is(first, tt.err) {
+                t.Errorf("expected %v, got %v", tt.err, first)
+            }
+        })
+    }
+}
+
+func TestStreamEncoderNilWriter(t *testing.T) {
+    if _, err := NewStreamEncoder(nil); err == nil {
+        t.Error("expected an error for a nil writer")
+    }
+}
@@ -0,0 +1,108 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "errors"
+    "strings"
+)
+
+// a decoding limit of the options was exceeded (the returned errors wrap it)
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+func limitError(limit string, max int) error {
+    return fmt.Errorf("%w: %s %d", ErrLimitExceeded, limit, max)
+}
+
+// a decoding error at a position of the input
+type DecodeError struct {
+    File string     // the input file name (or a description of the input)
+    Path string     // the path of the node the error occured on
+    Line int        // the line (starting from 1), 0 if unknown
+    Column int      // the column (starting from 1), 0 if unknown
+    Offset int      // the byte offset in the input, -1 if unknown
+    Err error       // the cause of the error
+}
+
+func (e *DecodeError) Error() string {
+    if e.Line <= 0 {
+        return fmt.Sprintf("%s: %s", e.File, e.Err.Error())
+    }
+    return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error {
+    return e.Err
+}
+
+func NewDecodeError(file string, event *Event, path *Path, err error) *DecodeError {
+
+    de := &DecodeError{
+        File: file,
+        Offset: -1,
+        Err: err,
+    }
+
+    if path != nil {
+        de.Path = path.String()
+    }
+
+    // prefer the token start mark
+    var m *Mark
+    if event != nil {
+        if t := event.Token(); t != nil {
+            m = t.StartMark()
+        }
+        if m == nil {
+            m = event.StartMark()
+        }
+    }
+
+    if m != nil {
+        de.Line = m.Line() + 1
+        de.Column = m.Column() + 1
+        de.Offset = m.InputPos()
+    }
+
+    return de
+}
+
+// a message of the parser
+type Diagnostic struct {
+    Severity DiagSeverity
+    File string     // the input file name (or a description of the input)
+    Line int        // the line (starting from 1)
+    Column int      // the column (starting from 1)
+    Message string
+    Excerpt string  // the source text the message refers to (if any)
+}
+
+func (d *Diagnostic) String() string {
+    str := fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+    if d.Excerpt != "" {
+        str += fmt.Sprintf(" (%q)", d.Excerpt)
+    }
+    return str
+}
+
+// all the messages of the parser, usable as an error
+type DiagnosticList []*Diagnostic
+
+func (dl DiagnosticList) Error() string {
+    lines := make([]string, len(dl))
+    for i, d := range dl {
+        lines[i] = d.String()
+    }
+    return strings.Join(lines, "\n")
+}
+
+// the messages of error severity
+func (dl DiagnosticList) Errors() DiagnosticList {
+    var errs DiagnosticList = nil
+    for _, d := range dl {
+        if d.Severity >= DiagError {
+            errs = append(errs, d)
+        }
+    }
+    return errs
+}
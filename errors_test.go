@@ -0,0 +1,179 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestDecodeErrorString(t *testing.T) {
+
+    cause := errors.New("bad value")
+
+    tests := []struct {
+        de DecodeError
+        expected string
+    }{
+        {
+            de: DecodeError{File: "a.yaml", Line: 2, Column: 4, Err: cause},
+            expected: "a.yaml:2:4: bad value",
+        }, {
+            de: DecodeError{File: "<data>", Offset: -1, Err: cause},
+            expected: "<data>: bad value",
+        },
+    }
+
+    for _, tt := range tests {
+        if str := tt.de.Error(); str != tt.expected {
+            t.Errorf("got %q, expected %q", str, tt.expected)
+        }
+        if !errors.Is(&tt.de, cause) {
+            t.Errorf("%v does not wrap the cause", &tt.de)
+        }
+    }
+}
+
+type positioned struct {
+    A int `yaml:"a"`
+    B []int `yaml:"b"`
+}
+
+func TestDecodeErrorPosition(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        into interface{}
+        line, column int
+        path string
+    }{
+        {
+            name: "mapping value",
+            input: "a: x\n",
+            into: new(positioned),
+            line: 1, column: 4,
+            path: "a",
+        }, {
+            name: "sequence item",
+            input: "a: 1\nb:\n- 1\n- y\n",
+            into: new(positioned),
+            line: 4, column: 3,
+            path: "b",
+        }, {
+            name: "unknown field",
+            input: "a: 1\n\nc: 2\n",
+            into: new(positioned),
+            line: 3, column: 1,
+            path: "c",
+        }, {
+            name: "out of range",
+            input: "[1, 2,\n  1000]\n",
+            into: new([]int8),
+            line: 2, column: 3,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            err := Unmarshal([]byte(tt.input), tt.into)
+
+            var de *DecodeError
+            if !errors.As(err, &de) {
+                t.Fatalf("expected a DecodeError, got %v", err)
+            }
+            if de.Line != tt.line || de.Column != tt.column {
+                t.Errorf("got %d:%d, expected %d:%d", de.Line, de.Column, tt.line, tt.column)
+            }
+            if !strings.Contains(de.Path, tt.path) {
+                t.Errorf("got path %q, expected it to contain %q", de.Path, tt.path)
+            }
+            if de.Offset < 0 {
+                t.Errorf("no offset")
+            }
+        })
+    }
+}
+
+func TestDecodeErrorFile(t *testing.T) {
+
+    filename := filepath.Join(t.TempDir(), "bad.yaml")
+    if err := os.WriteFile(filename, []byte("a: 1\nb: [x]\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    var v positioned
+    err := UnmarshalFile(filename, &v)
+
+    var de *DecodeError
+    if !errors.As(err, &de) {
+        t.Fatalf("expected a DecodeError, got %v", err)
+    }
+    if de.File != filename || de.Line != 2 {
+        t.Errorf("got %s:%d, expected %s:2", de.File, de.Line, filename)
+    }
+    if !strings.HasPrefix(err.Error(), filename + ":2:") {
+        t.Errorf("unexpected message %q", err.Error())
+    }
+}
+
+func TestDiagnosticList(t *testing.T) {
+
+    dl := DiagnosticList{
+        { Severity: DiagWarning, File: "a.yaml", Line: 1, Column: 2, Message: "odd" },
+        { Severity: DiagError, File: "a.yaml", Line: 3, Column: 4, Message: "bad", Excerpt: "[x" },
+    }
+
+    expected := "a.yaml:1:2: warning: odd\n" +
+                "a.yaml:3:4: error: bad (\"[x\")"
+    if str := dl.Error(); str != expected {
+        t.Errorf("got %q, expected %q", str, expected)
+    }
+
+    errs := dl.Errors()
+    if len(errs) != 1 || errs[0] != dl[1] {
+        t.Errorf("got %v, expected only the error", errs)
+    }
+
+    if errs := dl[:1].Errors(); errs != nil {
+        t.Errorf("got %v, expected no errors", errs)
+    }
+}
+
+func TestParserDiagnostics(t *testing.T) {
+
+    tests := []struct {
+        name string
+        input string
+        line int            // the first line the error can be on
+    }{
+        { name: "unterminated flow", input: "a: [1, 2\n", line: 1 },
+        { name: "bad indentation", input: "a:\n  b: 1\n c: 2\n", line: 3 },
+        { name: "unterminated quote", input: "a: 1\nb: \"x\n", line: 2 },
+        { name: "tab indentation", input: "a:\n\t- 1\n", line: 2 },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            var v interface{}
+            err := Unmarshal([]byte(tt.input), &v)
+
+            var dl DiagnosticList
+            if !errors.As(err, &dl) {
+                t.Fatalf("expected a DiagnosticList, got %v", err)
+            }
+
+            errs := dl.Errors()
+            if len(errs) == 0 {
+                t.Fatalf("no errors in %v", dl)
+            }
+            if d := errs[0]; d.File != "<data>" || d.Line < tt.line || d.Message == "" {
+                t.Errorf("unexpected diagnostic %v", d)
+            }
+        })
+    }
+}
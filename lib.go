@@ -3,6 +3,7 @@ package fyaml
 
 import (
     "fmt"
+    "io"
     "unsafe"
     "errors"
     gopointer "github.com/mattn/go-pointer"
@@ -261,6 +262,36 @@ func (t *Token) ScalarStyle() ScalarStyle {
     return ScalarStyle(C.fy_token_scalar_style(t.C()))
 }
 
+// return the start mark of the token
+func (t *Token) StartMark() *Mark {
+    return (*Mark)(C.fy_token_start_mark(t.C()))
+}
+
+// return the end mark of the token
+func (t *Token) EndMark() *Mark {
+    return (*Mark)(C.fy_token_end_mark(t.C()))
+}
+
+type Mark C.struct_fy_mark
+
+func (m *Mark) C() *C.struct_fy_mark {
+    return (*C.struct_fy_mark)(m)
+}
+
+// the byte offset in the input
+func (m *Mark) InputPos() int {
+    return int(m.input_pos)
+}
+
+// note that lines and columns start from 0
+func (m *Mark) Line() int {
+    return int(m.line)
+}
+
+func (m *Mark) Column() int {
+    return int(m.column)
+}
+
 type Version C.struct_fy_version
 
 func (v *Version) C() *C.struct_fy_version {
@@ -436,6 +467,11 @@ func (e *Event) Token() *Token {
     return (*Token)(C.fy_event_get_token(e.C()))
 }
 
+// return the start mark of an event (may be nil)
+func (e *Event) StartMark() *Mark {
+    return (*Mark)(C.fy_event_start_mark(e.C()))
+}
+
 // return the anchor Token of an event or nil if it does not exist
 func (e *Event) Anchor() *Token {
     return (*Token)(C.fy_event_get_anchor_token(e.C()))
@@ -536,6 +572,94 @@ func (ss ScalarStyle) String() string {
     return ""
 }
 
+type DiagSeverity int
+
+const (
+    DiagDebug DiagSeverity  = C.FYET_DEBUG
+    DiagInfo DiagSeverity   = C.FYET_INFO
+    DiagNotice DiagSeverity = C.FYET_NOTICE
+    DiagWarning DiagSeverity = C.FYET_WARNING
+    DiagError DiagSeverity  = C.FYET_ERROR
+)
+
+func (ds DiagSeverity) String() string {
+    switch ds {
+    case DiagDebug:
+        return "debug"
+    case DiagInfo:
+        return "info"
+    case DiagNotice:
+        return "notice"
+    case DiagWarning:
+        return "warning"
+    case DiagError:
+        return "error"
+    }
+    return ""
+}
+
+type Diag C.struct_fy_diag
+
+func (d *Diag) C() *C.struct_fy_diag {
+    return (*C.struct_fy_diag)(d)
+}
+
+// create a diagnostic object that collects the messages
+func DiagCreate() (*Diag, error) {
+
+    var cfg C.struct_fy_diag_cfg
+
+    C.fy_diag_cfg_default(&cfg)
+
+    d := (*Diag)(C.fy_diag_create(&cfg))
+    if d == nil {
+        return nil, errors.New("Failed to create diag\n")
+    }
+
+    // keep them instead of printing them
+    C.fy_diag_set_collect_errors(d.C(), true)
+
+    return d, nil
+}
+
+func (d *Diag) Destroy() {
+    if d == nil {
+        return
+    }
+    C.fy_diag_destroy(d.C())
+}
+
+// the collected messages; file is the name of the input
+func (d *Diag) Diagnostics(file string) DiagnosticList {
+
+    var dl DiagnosticList = nil
+    var prevp unsafe.Pointer = nil
+
+    for {
+        de := C.fy_diag_errors_iterate(d.C(), &prevp)
+        if de == nil {
+            break
+        }
+
+        diag := &Diagnostic{
+            Severity: DiagSeverity(de._type),
+            File: file,
+            Line: int(de.line) + 1,
+            Column: int(de.column) + 1,
+            Message: C.GoString(de.msg),
+        }
+
+        // the text of the offending token (if any)
+        if de.fyt != nil {
+            diag.Excerpt = (*Token)(de.fyt).Text()
+        }
+
+        dl = append(dl, diag)
+    }
+
+    return dl
+}
+
 type ParseCfg C.struct_fy_parse_cfg
 
 func (pc *ParseCfg) C() *C.struct_fy_parse_cfg {
@@ -557,6 +681,15 @@ func ParseCfgCreate(a CMemTrackerAllocator, opts...interface{}) (*ParseCfg, erro
     pc.search_path = a.CString("")
     pc.flags = 0    // expect 0, revisit if changes
 
+    // the diagnostics are collected (the parser keeps a reference)
+    d, err := DiagCreate()
+    if err != nil {
+        a.Free(unsafe.Pointer(pc.search_path))
+        a.Free(unsafe.Pointer(pc.C()))
+        return nil, err
+    }
+    pc.diag = d.C()
+
     if o.Quiet {
         pc.flags |= C.FYPCF_QUIET
     }
@@ -604,6 +737,11 @@ func (pc *ParseCfg) Destroy(a CMemTrackerAllocator) {
         a.Free(unsafe.Pointer(pc.search_path))
     }
 
+    // and drop our diag reference
+    if pc.diag != nil {
+        (*Diag)(pc.diag).Destroy()
+    }
+
     // and the C memory
     a.Free(unsafe.Pointer(pc.C()))
 }
@@ -635,12 +773,20 @@ func ParserCreate(a CMemTrackerAllocator, opts...interface{}) (*Parser, error) {
 
     p := (*Parser)(C.fy_parser_create(cfg.C()))
     if p == nil {
+        gopointer.Unref(cfg.userdata)
+        cfg.Destroy(a)
         return nil, errors.New("Failed to create parser\n")
     }
 
     return p, nil
 }
 
+// the diagnostic object of the parser
+func (p *Parser) Diag() *Diag {
+    cfg := C.fy_parser_get_cfg(p.C())
+    return (*Diag)(cfg.diag)
+}
+
 func (p *Parser) CMemTrackerAllocator() CMemTrackerAllocator {
     cfg := C.fy_parser_get_cfg(p.C())
     return gopointer.Restore(cfg.userdata).(CMemTrackerAllocator)
@@ -672,7 +818,12 @@ func (p *Parser) Destroy() {
     cfg := (*ParseCfg)(C.fy_parser_get_cfg(p.C()))
     gopointer.Unref(cfg.userdata)
 
+    // the diag outlives the parser's reference
+    d := p.Diag()
+
     C.fy_parser_destroy(p.C())
+
+    d.Destroy()
 }
 
 func (p *Parser) SetInputFile(file string) error {
@@ -693,6 +844,65 @@ func (p *Parser) SetInputData(data unsafe.Pointer, size uint) error {
     return nil
 }
 
+// the userdata must be a saved InputReader object
+func (p *Parser) SetInputCallback(userdata unsafe.Pointer) error {
+
+    if rc := C.fy_parser_set_input_callback(p.C(), userdata, (*[0]byte)(C.input_read)); rc != 0 {
+        return errors.New("failed to set input callback")
+    }
+    return nil
+}
+
+// the number of empty reads before giving up on a reader
+const maxEmptyReads = 100
+
+type InputReader interface {
+    ReadInput(buf []byte) (n int, err error)
+    SetError(err error)
+}
+
+//export FY_InputRead
+func FY_InputRead(userdata *C.void, buf *C.void, count C.size_t) C.ssize_t {
+
+    var data unsafe.Pointer = unsafe.Pointer(userdata)
+
+    if data == nil {
+        panic("Userdata nil in FY_InputRead callback")
+    }
+
+    // restore the InputReader interface object
+    reader := gopointer.Restore(data).(InputReader)
+
+    // read directly into the parser's buffer
+    bufSlice := unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(count))
+
+    for tries := 0; ; tries++ {
+        n, err := reader.ReadInput(bufSlice)
+
+        // return what we've got; a pending error is returned on the next call
+        if n > 0 {
+            return C.ssize_t(n)
+        }
+
+        // end of input
+        if err == io.EOF {
+            return 0
+        }
+
+        // a reader that never returns anything is broken
+        if err == nil && tries >= maxEmptyReads {
+            err = io.ErrNoProgress
+        }
+
+        if err != nil {
+            reader.SetError(err)
+            return -1
+        }
+
+        // nothing read and no error, try again
+    }
+}
+
 type EventProcessor interface {
     ProcessEvent(e *Event, path *Path) (stop bool, err error)
     SetError(err error)
@@ -879,6 +1089,11 @@ func EmitterCreate(a CMemTrackerAllocator, opts...interface{}) (*Emitter, error)
     // save the allocator (and associated object)
     cfg.userdata = gopointer.Save(a)
 
+    // if the associated object takes output, route it there
+    if _, isOw := a.(OutputWriter); isOw {
+        cfg.output = (*[0]byte)(C.emitter_output)
+    }
+
     e := (*Emitter)(C.fy_emitter_create(cfg.C()))
     if e == nil {
         return nil, errors.New("Failed to create emitter\n")
@@ -887,6 +1102,37 @@ func EmitterCreate(a CMemTrackerAllocator, opts...interface{}) (*Emitter, error)
     return e, nil
 }
 
+type OutputWriter interface {
+    WriteOutput(buf []byte) (n int, err error)
+    SetError(err error)
+}
+
+//export FY_EmitterOutput
+func FY_EmitterOutput(emit *C.struct_fy_emitter, wtype C.enum_fy_emitter_write_type, str *C.char, length C.int, userdata *C.void) C.int {
+
+    var data unsafe.Pointer = unsafe.Pointer(userdata)
+
+    if data == nil {
+        panic("Userdata nil in FY_EmitterOutput callback")
+    }
+
+    if length <= 0 {
+        return 0
+    }
+
+    // restore the OutputWriter interface object
+    writer := gopointer.Restore(data).(OutputWriter)
+
+    // write directly from the emitter's buffer
+    n, err := writer.WriteOutput(unsafe.Slice((*byte)(unsafe.Pointer(str)), int(length)))
+    if err != nil {
+        writer.SetError(err)
+        return -1
+    }
+
+    return C.int(n)
+}
+
 func (e *Emitter) CMemTrackerAllocator() CMemTrackerAllocator {
     cfg := C.fy_emitter_get_cfg(e.C())
     return gopointer.Restore(cfg.userdata).(CMemTrackerAllocator)
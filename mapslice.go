@@ -0,0 +1,81 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "reflect"
+)
+
+// a key/value pair of an ordered mapping
+type MapItem struct {
+    Key, Value interface{}
+}
+
+// an ordered mapping; the keys are kept in document (or insertion) order
+// the decoder fills it for generic values with the ordered-maps option
+type MapSlice []MapItem
+
+var mapSliceType = reflect.TypeOf(MapSlice{})
+
+// the value of a key, and whether it exists
+func (ms MapSlice) Get(key interface{}) (interface{}, bool) {
+    for _, item := range ms {
+        if reflect.DeepEqual(item.Key, key) {
+            return item.Value, true
+        }
+    }
+    return nil, false
+}
+
+// convert to a regular map (the order is lost)
+// complex keys take their canonical (hashable) form
+func (ms MapSlice) Map() (map[interface{}]interface{}, error) {
+    m := make(map[interface{}]interface{}, len(ms))
+    for _, item := range ms {
+        key, err := orderedKey(reflect.ValueOf(&item.Key).Elem())
+        if err != nil {
+            return nil, err
+        }
+        m[key] = item.Value
+    }
+    return m, nil
+}
+
+// the canonical form of a key of an ordered mapping for duplicate checks
+func orderedKey(key reflect.Value) (interface{}, error) {
+    if !IsHashable(key) {
+        ckey, err := CanonicalKey(key)
+        if err != nil {
+            return nil, err
+        }
+        key = ckey
+    }
+    if !key.IsValid() {
+        return nil, nil
+    }
+    return key.Interface(), nil
+}
+
+// an ordered mapping with unique keys, as a sequence of single pair mappings (!!omap)
+type Omap []MapItem
+
+// an ordered list of key/value pairs, where keys might repeat (!!pairs)
+type Pairs []MapItem
+
+var omapType = reflect.TypeOf(Omap{})
+var pairsType = reflect.TypeOf(Pairs{})
+var mapItemType = reflect.TypeOf(MapItem{})
+var emptyStructType = reflect.TypeOf(struct{}{})
+
+// slices of key/value pairs (MapSlice, Omap, Pairs)
+func isPairsType(t reflect.Type) bool {
+    return t.Kind() == reflect.Slice && t.Elem() == mapItemType
+}
+
+// sets are maps of empty struct values (!!set)
+func isSetType(t reflect.Type) bool {
+    if t.Kind() != reflect.Map {
+        return false
+    }
+    et := t.Elem()
+    return et.Kind() == reflect.Struct && et.NumField() == 0
+}
@@ -0,0 +1,331 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestMapSliceGet(t *testing.T) {
+
+    ms := MapSlice{{"b", 1}, {2, "two"}, {nil, "null"}, {[]interface{}{"x"}, "seq"}}
+
+    tests := []struct {
+        key interface{}
+        value interface{}
+        found bool
+    }{
+        { key: "b", value: 1, found: true },
+        { key: 2, value: "two", found: true },
+        { key: nil, value: "null", found: true },
+        { key: []interface{}{"x"}, value: "seq", found: true },
+        { key: "2", found: false },
+    }
+
+    for _, tt := range tests {
+        value, found := ms.Get(tt.key)
+        if found != tt.found || !reflect.DeepEqual(value, tt.value) {
+            t.Errorf("%#v: got %#v, %v; expected %#v, %v", tt.key, value, found, tt.value, tt.found)
+        }
+    }
+}
+
+func TestMapSliceMap(t *testing.T) {
+
+    tests := []struct {
+        name string
+        ms MapSlice
+        expected map[interface{}]interface{}
+        fails bool
+    }{
+        {
+            name: "scalar keys",
+            ms: MapSlice{{"a", 1}, {2, "b"}},
+            expected: map[interface{}]interface{}{"a": 1, 2: "b"},
+        }, {
+            name: "complex key",
+            ms: MapSlice{{[]interface{}{"x", 1}, "v"}},
+            expected: map[interface{}]interface{}{[2]interface{}{"x", 1}: "v"},
+        }, {
+            name: "empty",
+            ms: MapSlice{},
+            expected: map[interface{}]interface{}{},
+        }, {
+            name: "unhashable key",
+            ms: MapSlice{{[]interface{}{func() {}}, "v"}},
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            m, err := tt.ms.Map()
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got %#v", m)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(m, tt.expected) {
+                t.Errorf("got %#v, expected %#v", m, tt.expected)
+            }
+        })
+    }
+}
+
+func TestDecodeOrderedMaps(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "document order",
+            input: "z: 1\na: 2\nm: 3\n",
+            into: new(MapSlice),
+            expected: MapSlice{{"z", 1}, {"a", 2}, {"m", 3}},
+        }, {
+            name: "nested under a map slice root",
+            input: "z: {y: 1, x: 2}\na: [{c: 3, b: 4}]\n",
+            into: new(MapSlice),
+            expected: MapSlice{
+                {"z", MapSlice{{"y", 1}, {"x", 2}}},
+                {"a", []interface{}{MapSlice{{"c", 3}, {"b", 4}}}},
+            },
+        }, {
+            name: "generic with the option",
+            input: "b: 1\na: {d: 2, c: 3}\n",
+            opts: []interface{}{"ordered-maps"},
+            into: new(interface{}),
+            expected: MapSlice{{"b", 1}, {"a", MapSlice{{"d", 2}, {"c", 3}}}},
+        }, {
+            name: "generic without the option",
+            input: "b: 1\na: 2\n",
+            into: new(interface{}),
+            expected: map[interface{}]interface{}{"a": 2, "b": 1},
+        }, {
+            name: "merged keys follow the explicit ones",
+            input: "base: &b {y: 1, x: 2}\nm: {c: 3, <<: *b, a: 4}\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(MapSlice),
+            expected: MapSlice{
+                {"base", MapSlice{{"y", 1}, {"x", 2}}},
+                {"m", MapSlice{{"c", 3}, {"a", 4}, {"y", 1}, {"x", 2}}},
+            },
+        }, {
+            name: "duplicate key",
+            input: "a: 1\nb: 2\na: 3\n",
+            into: new(MapSlice),
+            fails: true,
+        }, {
+            name: "not a mapping",
+            input: "[a, b]\n",
+            into: new(MapSlice),
+            fails: true,
+        },
+    })
+}
+
+func TestMarshalMapSlice(t *testing.T) {
+
+    tests := []struct {
+        name string
+        value interface{}
+        keys []string
+        expected MapSlice
+    }{
+        {
+            name: "insertion order",
+            value: MapSlice{{"z", 1}, {"a", 2}, {"m", 3}},
+            keys: []string{"z", "a", "m"},
+            expected: MapSlice{{"z", 1}, {"a", 2}, {"m", 3}},
+        }, {
+            name: "nested",
+            value: map[string]interface{}{"x": MapSlice{{"c", 1}, {"b", 2}}},
+            keys: []string{"x", "c", "b"},
+            expected: MapSlice{{"x", MapSlice{{"c", 1}, {"b", 2}}}},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            // the keys appear in order
+            text := string(data)
+            at := 0
+            for _, key := range tt.keys {
+                i := strings.Index(text[at:], key + ":")
+                if i < 0 {
+                    t.Fatalf("%s out of order in:\n%s", key, data)
+                }
+                at += i + len(key)
+            }
+
+            var back MapSlice
+            if err := Unmarshal(data, &back); err != nil {
+                t.Fatalf("%v in:\n%s", err, data)
+            }
+            if !reflect.DeepEqual(back, tt.expected) {
+                t.Errorf("got %#v, expected %#v", back, tt.expected)
+            }
+        })
+    }
+}
+
+func TestDecodeCollectionTags(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "set of empty structs",
+            input: "!!set\n? a\n? b\n",
+            into: new(map[string]struct{}),
+            expected: map[string]struct{}{"a": {}, "b": {}},
+        }, {
+            name: "set of bools",
+            input: "!!set {a, b}\n",
+            into: new(map[string]bool),
+            expected: map[string]bool{"a": true, "b": true},
+        }, {
+            name: "set to generic",
+            input: "!!set {1, 2}\n",
+            into: new(interface{}),
+            expected: map[int]struct{}{1: {}, 2: {}},
+        }, {
+            name: "set of mixed keys to generic",
+            input: "!!set {1, a}\n",
+            into: new(interface{}),
+            expected: map[interface{}]struct{}{1: {}, "a": {}},
+        }, {
+            name: "set with a value",
+            input: "!!set {a: 1}\n",
+            into: new(map[string]struct{}),
+            fails: true,
+        }, {
+            name: "set to a map of values",
+            input: "!!set {a}\n",
+            into: new(map[string]int),
+            fails: true,
+        }, {
+            name: "omap",
+            input: "!!omap\n- b: 1\n- a: 2\n",
+            into: new(Omap),
+            expected: Omap{{"b", 1}, {"a", 2}},
+        }, {
+            name: "omap to generic",
+            input: "!!omap [b: 1, a: 2]\n",
+            into: new(interface{}),
+            expected: Omap{{"b", 1}, {"a", 2}},
+        }, {
+            name: "omap with a duplicate key",
+            input: "!!omap [a: 1, a: 2]\n",
+            into: new(Omap),
+            fails: true,
+        }, {
+            name: "omap item not a pair",
+            input: "!!omap [{a: 1, b: 2}]\n",
+            into: new(Omap),
+            fails: true,
+        }, {
+            name: "omap item not a mapping",
+            input: "!!omap [a]\n",
+            into: new(Omap),
+            fails: true,
+        }, {
+            name: "pairs with a repeated key",
+            input: "!!pairs [a: 1, b: 2, a: 3]\n",
+            into: new(Pairs),
+            expected: Pairs{{"a", 1}, {"b", 2}, {"a", 3}},
+        }, {
+            name: "pairs to generic",
+            input: "!!pairs [a: 1]\n",
+            into: new(interface{}),
+            expected: Pairs{{"a", 1}},
+        }, {
+            name: "pairs to a struct",
+            input: "!!pairs [a: 1]\n",
+            into: new(container),
+            fails: true,
+        },
+    })
+}
+
+func TestMarshalCollectionTags(t *testing.T) {
+
+    tests := []struct {
+        name string
+        value interface{}
+        opts []interface{}
+        contains []string
+        excludes []string
+    }{
+        {
+            name: "set",
+            value: map[string]struct{}{"a": {}},
+            contains: []string{"!!set"},
+        }, {
+            name: "omap",
+            value: Omap{{"a", 1}},
+            contains: []string{"!!omap"},
+        }, {
+            name: "pairs",
+            value: Pairs{{"a", 1}},
+            contains: []string{"!!pairs"},
+        }, {
+            name: "json",
+            value: []interface{}{map[string]struct{}{"a": {}}, Omap{{"a", 1}}},
+            opts: []interface{}{"output-mode=json"},
+            excludes: []string{"!!"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value, tt.opts...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, s := range tt.contains {
+                if !strings.Contains(string(data), s) {
+                    t.Errorf("%q missing from:\n%s", s, data)
+                }
+            }
+            for _, s := range tt.excludes {
+                if strings.Contains(string(data), s) {
+                    t.Errorf("unexpected %q in:\n%s", s, data)
+                }
+            }
+        })
+    }
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "set",
+            value: map[int]struct{}{3: {}, 1: {}},
+            back: new(interface{}),
+            expected: map[int]struct{}{1: {}, 3: {}},
+        }, {
+            name: "omap",
+            value: Omap{{"b", 1}, {"a", []interface{}{2}}},
+            back: new(interface{}),
+            expected: Omap{{"b", 1}, {"a", []interface{}{2}}},
+        }, {
+            name: "pairs",
+            value: Pairs{{"a", 1}, {"a", 2}},
+            back: new(Pairs),
+            expected: Pairs{{"a", 1}, {"a", 2}},
+        }, {
+            name: "a map of bools is not a set",
+            value: struct{ S map[string]bool `yaml:"s"` }{map[string]bool{"x": true}},
+            back: new(map[string]map[string]interface{}),
+            expected: map[string]map[string]interface{}{"s": {"x": true}},
+        },
+    })
+}
@@ -2,77 +2,473 @@
 package fyaml
 
 import (
+    "encoding"
     "reflect"
     "errors"
     "strconv"
+    "sort"
+    "strings"
+    "unicode"
     "fmt"
+    "time"
 )
 
-func (enc *Encoder) emitMarshalMap(e *Emitter, rv reflect.Value) error {
-    if err := e.EmitEvent(MappingStart, AnyStyle, "", ""); err != nil {
+// the node style of a collection, as hinted by the struct field (if any)
+func collectionStyle(f *Field) NodeStyle {
+    var ns NodeStyle = AnyStyle
+    if f != nil && f.flow {
+        ns = FlowStyle
+    }
+    return ns
+}
+
+// the rank of a key in the natural order of mixed types
+func keyRank(rv reflect.Value) int {
+    switch rv.Kind() {
+    case reflect.Invalid:
+        return 0
+    case reflect.Bool:
+        return 1
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+         reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+         reflect.Float32, reflect.Float64:
+        return 2
+    case reflect.String:
+        return 3
+    }
+    return 4
+}
+
+// whether a number is a signed int, an unsigned int or a float
+func numberKind(rv reflect.Value) (bool, bool, bool) {
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return true, false, false
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return false, true, false
+    }
+    return false, false, true
+}
+
+func numberFloat(rv reflect.Value, isInt, isUint bool) float64 {
+    switch {
+    case isInt:
+        return float64(rv.Int())
+    case isUint:
+        return float64(rv.Uint())
+    }
+    return rv.Float()
+}
+
+// the natural order of map keys: numbers numerically, strings lexically,
+// and mixed types by kind (null, bool, number, string, anything else)
+// the keys equal in that order (i.e. 1 and 1.0) go by type and then
+// by their contents, so that the order is total
+func NaturalKeyLess(a, b interface{}) bool {
+    return keyLess(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func keyLess(rva, rvb reflect.Value) bool {
+
+    for rva.Kind() == reflect.Interface || rva.Kind() == reflect.Ptr && !rva.IsNil() {
+        rva = rva.Elem()
+    }
+    for rvb.Kind() == reflect.Interface || rvb.Kind() == reflect.Ptr && !rvb.IsNil() {
+        rvb = rvb.Elem()
+    }
+
+    if naturalLess(rva, rvb) {
+        return true
+    }
+    if naturalLess(rvb, rva) {
+        return false
+    }
+
+    ta, tb := keyTypeText(rva), keyTypeText(rvb)
+    if ta != tb {
+        return ta < tb
+    }
+
+    // the same type; the composite keys go by their members
+    switch rva.Kind() {
+    case reflect.Array:
+        for i := 0; i < rva.Len(); i++ {
+            if keyLess(rva.Index(i), rvb.Index(i)) {
+                return true
+            }
+            if keyLess(rvb.Index(i), rva.Index(i)) {
+                return false
+            }
+        }
+        return false
+
+    case reflect.Struct:
+        for i := 0; i < rva.NumField(); i++ {
+            if keyLess(rva.Field(i), rvb.Field(i)) {
+                return true
+            }
+            if keyLess(rvb.Field(i), rva.Field(i)) {
+                return false
+            }
+        }
+        return false
+    }
+
+    return keyGoText(rva) < keyGoText(rvb)
+}
+
+func keyTypeText(rv reflect.Value) string {
+    if !rv.IsValid() {
+        return ""
+    }
+    return rv.Type().String()
+}
+
+func keyGoText(rv reflect.Value) string {
+    if !rv.IsValid() || !rv.CanInterface() {
+        return ""
+    }
+    return fmt.Sprintf("%#v", rv.Interface())
+}
+
+// the natural order of the (dereferenced) keys
+func naturalLess(rva, rvb reflect.Value) bool {
+
+    ra, rb := keyRank(rva), keyRank(rvb)
+    if ra != rb {
+        return ra < rb
+    }
+
+    switch ra {
+    case 1:
+        return !rva.Bool() && rvb.Bool()
+
+    case 2:
+        ia, ua, _ := numberKind(rva)
+        ib, ub, _ := numberKind(rvb)
+        switch {
+        case ia && ib:
+            return rva.Int() < rvb.Int()
+        case ua && ub:
+            return rva.Uint() < rvb.Uint()
+        case ia && ub:
+            return rva.Int() < 0 || uint64(rva.Int()) < rvb.Uint()
+        case ua && ib:
+            return rvb.Int() >= 0 && rva.Uint() < uint64(rvb.Int())
+        }
+        return numberFloat(rva, ia, ua) < numberFloat(rvb, ib, ub)
+
+    case 3:
+        return rva.String() < rvb.String()
+
+    case 4:
+        if rva.Kind() != rvb.Kind() {
+            return rva.Kind() < rvb.Kind()
+        }
+        if !rva.CanInterface() || !rvb.CanInterface() {
+            return false
+        }
+        return fmt.Sprint(rva.Interface()) < fmt.Sprint(rvb.Interface())
+    }
+
+    return false
+}
+
+// the keys of a map in the order of the options
+func (enc *Encoder) sortedMapKeys(rv reflect.Value) []reflect.Value {
+
+    less := enc.opts.MapKeyLess
+    if less == nil {
+        less = NaturalKeyLess
+    }
+
+    keys := rv.MapKeys()
+    sort.SliceStable(keys, func(i, j int) bool {
+        return less(keys[i].Interface(), keys[j].Interface())
+    })
+    return keys
+}
+
+// JSON object keys are strings, so the number and bool keys are quoted
+var jsonKeyField = &Field{ asString: true }
+
+func (enc *Encoder) keyField() *Field {
+    if enc.jsonOutput {
+        return jsonKeyField
+    }
+    return nil
+}
+
+func (enc *Encoder) emitMarshalMap(e *Emitter, rv reflect.Value, f *Field) error {
+    if err := e.EmitEvent(MappingStart, collectionStyle(f), enc.takeAnchor(), ""); err != nil {
         return err
     }
-    for _, key := range rv.MapKeys() {
-        if err := enc.emitMarshal(e, key); err != nil {
+    for _, key := range enc.sortedMapKeys(rv) {
+        if err := enc.emitMarshal(e, key, enc.keyField()); err != nil {
             return err
         }
-        if err := enc.emitMarshal(e, rv.MapIndex(key)); err != nil {
+        if err := enc.emitMarshal(e, rv.MapIndex(key), nil); err != nil {
             return err
         }
     }
     return e.EmitEvent(MappingEnd)
 }
 
-func (enc *Encoder) emitMarshalStruct(e *Emitter, rv reflect.Value) error {
+// ordered mappings are emitted in order
+func (enc *Encoder) emitMarshalMapSlice(e *Emitter, rv reflect.Value, f *Field) error {
+    if err := e.EmitEvent(MappingStart, collectionStyle(f), enc.takeAnchor(), ""); err != nil {
+        return err
+    }
+    for i := 0; i < rv.Len(); i++ {
+        item := rv.Index(i)
+        if err := enc.emitMarshal(e, item.Field(0), enc.keyField()); err != nil {
+            return err
+        }
+        if err := enc.emitMarshal(e, item.Field(1), nil); err != nil {
+            return err
+        }
+    }
+    return e.EmitEvent(MappingEnd)
+}
+
+// the tags of the 1.1 types are dropped for JSON
+func (enc *Encoder) typeTag(tag string) string {
+    if enc.jsonOutput {
+        return ""
+    }
+    return tag
+}
+
+// sets are mappings of null values
+func (enc *Encoder) emitMarshalSet(e *Emitter, rv reflect.Value, f *Field) error {
+    if err := e.EmitEvent(MappingStart, collectionStyle(f), enc.takeAnchor(), enc.typeTag("!!set")); err != nil {
+        return err
+    }
+    for _, key := range enc.sortedMapKeys(rv) {
+        if err := enc.emitMarshal(e, key, enc.keyField()); err != nil {
+            return err
+        }
+        if err := enc.emitMarshalNull(e, reflect.Value{}); err != nil {
+            return err
+        }
+    }
+    return e.EmitEvent(MappingEnd)
+}
+
+// omaps and pairs are sequences of single pair mappings
+func (enc *Encoder) emitMarshalPairs(e *Emitter, rv reflect.Value, f *Field, tag string) error {
+    if err := e.EmitEvent(SequenceStart, collectionStyle(f), enc.takeAnchor(), enc.typeTag(tag)); err != nil {
+        return err
+    }
+    for i := 0; i < rv.Len(); i++ {
+        item := rv.Index(i)
+        if err := e.EmitEvent(MappingStart, AnyStyle, "", ""); err != nil {
+            return err
+        }
+        if err := enc.emitMarshal(e, item.Field(0), enc.keyField()); err != nil {
+            return err
+        }
+        if err := enc.emitMarshal(e, item.Field(1), nil); err != nil {
+            return err
+        }
+        if err := e.EmitEvent(MappingEnd); err != nil {
+            return err
+        }
+    }
+    return e.EmitEvent(SequenceEnd)
+}
+
+func (enc *Encoder) emitMarshalStruct(e *Emitter, rv reflect.Value, f *Field) error {
 
     // lookup the type info
     ti := enc.sc.LookupOrNewType(rv.Type())
     if ti == nil {
-        return errors.New(fmt.Sprintf("%s: could not lookup type %s\n", rv.Type()))
+        return errors.New(fmt.Sprintf("could not lookup type %s\n", rv.Type()))
     }
-    if err := e.EmitEvent(MappingStart, AnyStyle, "", ""); err != nil {
+    if err := e.EmitEvent(MappingStart, collectionStyle(f), enc.takeAnchor(), ""); err != nil {
         return err
     }
-    for i, f := range ti.fields {
-        if err := enc.emitMarshal(e, reflect.ValueOf(f.name)); err != nil {
+    for _, ff := range ti.fields {
+
+        // skip ignored (and unexported) fields
+        if ff.ignored {
+            continue
+        }
+
+        // promoted through a nil embedded pointer
+        rvf := FieldByIndexRead(rv, ff.index)
+        if !rvf.IsValid() {
+            continue
+        }
+
+        if ff.omitempty && IsEmptyValue(rvf) {
+            continue
+        }
+
+        if err := enc.emitMarshal(e, reflect.ValueOf(ff.name), nil); err != nil {
             return err
         }
-        if err := enc.emitMarshal(e, rv.Field(i)); err != nil {
+        if err := enc.emitMarshal(e, rvf, ff); err != nil {
             return err
         }
     }
+
+    // the inline map keys follow
+    if ti.inlineMap != nil {
+        rvm := FieldByIndexRead(rv, ti.inlineMap.index)
+        if rvm.IsValid() && !rvm.IsNil() {
+            for _, key := range enc.sortedMapKeys(rvm) {
+                if _, conflict := ti.tagToField[key.String()]; conflict && key.Kind() == reflect.String {
+                    return errors.New(fmt.Sprintf("inline map key %s conflicts with a field of %s", key.String(), rv.Type()))
+                }
+                if err := enc.emitMarshal(e, key, enc.keyField()); err != nil {
+                    return err
+                }
+                if err := enc.emitMarshal(e, rvm.MapIndex(key), nil); err != nil {
+                    return err
+                }
+            }
+        }
+    }
+
     return e.EmitEvent(MappingEnd)
 }
 
-func (enc *Encoder) emitMarshalSlice(e *Emitter, rv reflect.Value) error {
-    if err := e.EmitEvent(SequenceStart, AnyStyle, "", ""); err != nil {
+func (enc *Encoder) emitMarshalSlice(e *Emitter, rv reflect.Value, f *Field) error {
+    if err := e.EmitEvent(SequenceStart, collectionStyle(f), enc.takeAnchor(), ""); err != nil {
         return err
     }
     for i := 0; i < rv.Len(); i++ {
-        if err := enc.emitMarshal(e, rv.Index(i)); err != nil {
+        if err := enc.emitMarshal(e, rv.Index(i), nil); err != nil {
             return err
         }
     }
     return e.EmitEvent(SequenceEnd)
 }
 
-func (enc *Encoder) emitMarshalString(e *Emitter, rv reflect.Value) error {
-    // XXX schema string
+// byte slices are !!binary (base64) scalars; plain base64 strings for JSON
+func (enc *Encoder) emitMarshalBinary(e *Emitter, rv reflect.Value, f *Field) error {
+
+    if enc.jsonOutput {
+        str := encodeBinary(rv.Bytes(), 0)
+        return e.EmitEvent(Scalar, DoubleQuoted, str, enc.takeAnchor(), "")
+    }
+
+    // in block mode as literal, split in lines
+    if enc.blockOutput() {
+        str := encodeBinary(rv.Bytes(), binaryLineWidth)
+        return e.EmitEvent(Scalar, Literal, str, enc.takeAnchor(), "!!binary")
+    }
+
+    str := encodeBinary(rv.Bytes(), 0)
+    return e.EmitEvent(Scalar, Any, str, enc.takeAnchor(), "!!binary")
+}
+
+// true if a plain scalar would not resolve back to a string
+func (enc *Encoder) needsQuoting(str string) bool {
+
+    ysp, hasYsp := enc.si.(YAMLSchemaProvider)
+    if !hasYsp {
+        return false
+    }
+
+    // timestamps might be enabled for the core schemas
+    if TimestampsEnabled(enc.si, enc.opts) && isTimestamp(str) {
+        return true
+    }
+
+    th := ysp.YAMLSchema().ImplicitResolve(&str)
+
+    return th != nil && th.Tag() != DefaultLongTagPrefix + "str"
+}
+
+// is the output in block mode
+func (enc *Encoder) blockOutput() bool {
+    switch enc.opts.OutputMode {
+    case "", "original", "block", "pretty":
+        return true
+    }
+    return false
+}
+
+// the block scalar style of a multiline string (Any if not possible)
+// long lines of prose are folded, everything else is literal
+func (enc *Encoder) blockScalarStyle(str string) ScalarStyle {
+
+    if !strings.Contains(str, "\n") {
+        return Any
+    }
+
+    width := enc.opts.Width
+    if width <= 0 {
+        width = 80
+    }
+
+    prose := false
+    for _, line := range strings.Split(str, "\n") {
+        for _, r := range line {
+            // block scalars can't escape anything
+            if r != '\t' && !unicode.IsPrint(r) {
+                return Any
+            }
+        }
+        if len(line) > width && strings.Contains(line, " ") {
+            prose = true
+        }
+    }
+
+    if prose {
+        return Folded
+    }
+    return Literal
+}
+
+// the emitter falls back to a quoted style where a block scalar
+// is not possible (i.e. in flow collections)
+func (enc *Encoder) emitMarshalString(e *Emitter, rv reflect.Value, f *Field) error {
     str := rv.String()
-    return e.EmitEvent(Scalar, Any, str, "", "")
+
+    var ss ScalarStyle = Any
+    switch {
+    // the field hints take precedence
+    case f != nil && f.quoted:
+        ss = DoubleQuoted
+    case f != nil && f.literal:
+        ss = Literal
+    case f != nil && f.folded:
+        ss = Folded
+
+    // strings that look like other types are quoted
+    case enc.needsQuoting(str):
+        ss = DoubleQuoted
+
+    case enc.blockOutput():
+        ss = enc.blockScalarStyle(str)
+    }
+
+    return e.EmitEvent(Scalar, ss, str, enc.takeAnchor(), "")
+}
+
+// the scalar style of a number or bool; quoted for ,string fields
+func valueScalarStyle(f *Field) ScalarStyle {
+    var ss ScalarStyle = Plain
+    if f != nil && f.asString {
+        ss = DoubleQuoted
+    }
+    return ss
 }
 
-func (enc *Encoder) emitMarshalInt(e *Emitter, rv reflect.Value) error {
+func (enc *Encoder) emitMarshalInt(e *Emitter, rv reflect.Value, f *Field) error {
     str := strconv.FormatInt(rv.Int(), 10)
-    return e.EmitEvent(Scalar, Plain, str, "", "")
+    return e.EmitEvent(Scalar, valueScalarStyle(f), str, enc.takeAnchor(), "")
 }
 
-func (enc *Encoder) emitMarshalUint(e *Emitter, rv reflect.Value) error {
+func (enc *Encoder) emitMarshalUint(e *Emitter, rv reflect.Value, f *Field) error {
     str := strconv.FormatUint(rv.Uint(), 10)
-    return e.EmitEvent(Scalar, Plain, str, "", "")
+    return e.EmitEvent(Scalar, valueScalarStyle(f), str, enc.takeAnchor(), "")
 }
 
-func (enc *Encoder) emitMarshalFloat(e *Emitter, rv reflect.Value) error {
+func (enc *Encoder) emitMarshalFloat(e *Emitter, rv reflect.Value, f *Field) error {
 	p := 64
 	if rv.Kind() == reflect.Float32 {
 		p = 32
@@ -87,17 +483,46 @@ func (enc *Encoder) emitMarshalFloat(e *Emitter, rv reflect.Value) error {
 	case "NaN":
 		str = ".nan"
 	}
-    return e.EmitEvent(Scalar, Plain, str, "", "")
+
+    // 1.1 floats must have a fraction, else they resolve to ints
+    if ysp, hasYsp := enc.si.(YAMLSchemaProvider); hasYsp && ysp.YAMLSchema().YAMLSchemaType() == YAML11Schema {
+        if !yaml11FloatRe.MatchString(str) {
+            if i := strings.IndexByte(str, 'e'); i >= 0 {
+                str = str[:i] + ".0" + str[i:]
+            } else {
+                str = str + ".0"
+            }
+        }
+    }
+    return e.EmitEvent(Scalar, valueScalarStyle(f), str, enc.takeAnchor(), "")
 }
 
-func (enc *Encoder) emitMarshalBool(e *Emitter, rv reflect.Value) error {
+func (enc *Encoder) emitMarshalBool(e *Emitter, rv reflect.Value, f *Field) error {
     var str string
     if rv.Bool() {
         str = "true"
     } else {
         str = "false"
     }
-    return e.EmitEvent(Scalar, Plain, str, "", "")
+    return e.EmitEvent(Scalar, valueScalarStyle(f), str, enc.takeAnchor(), "")
+}
+
+// timestamps in RFC3339 with nanoseconds (a valid YAML timestamp)
+func (enc *Encoder) emitMarshalTime(e *Emitter, rv reflect.Value, f *Field) error {
+    str := rv.Interface().(time.Time).Format(time.RFC3339Nano)
+
+    // plain, so that it resolves back to a timestamp where possible
+    ss := valueScalarStyle(f)
+    if enc.jsonOutput {
+        ss = DoubleQuoted
+    }
+    return e.EmitEvent(Scalar, ss, str, enc.takeAnchor(), "")
+}
+
+// durations as go duration strings (i.e. 1h30m0s)
+func (enc *Encoder) emitMarshalDuration(e *Emitter, rv reflect.Value, f *Field) error {
+    str := time.Duration(rv.Int()).String()
+    return enc.emitMarshalString(e, reflect.ValueOf(str), f)
 }
 
 func (enc *Encoder) emitMarshalNull(e *Emitter, rv reflect.Value) error {
@@ -108,41 +533,256 @@ func (enc *Encoder) emitMarshalNull(e *Emitter, rv reflect.Value) error {
     } else {
         str = "null"    // or the JSON null
     }
-    return e.EmitEvent(Scalar, Plain, str, "", "")
+    return e.EmitEvent(Scalar, Plain, str, enc.takeAnchor(), "")
 }
 
-func (enc *Encoder) emitMarshal(e *Emitter, rv reflect.Value) error {
+// returns true if the value implemented a custom marshaler
+func (enc *Encoder) emitMarshalCustom(e *Emitter, rv reflect.Value, f *Field) (bool, error) {
 
-    if !rv.IsValid() || rv.Kind() == reflect.Ptr && rv.IsNil() {
-        return enc.emitMarshalNull(e, rv)
+    // not possible for unexported values
+    if !rv.CanInterface() {
+        return false, nil
     }
 
-    // placeholder for interface
     iface := rv.Interface()
+
+    // try with the pointer receiver methods too
+    if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+        switch rv.Addr().Interface().(type) {
+        case Marshaler, encoding.TextMarshaler:
+            iface = rv.Addr().Interface()
+        }
+    }
+
     switch v := iface.(type) {
-    default:
-        _ = v
+    case Marshaler:
+        out, err := v.MarshalYAML()
+        if err != nil {
+            return true, err
+        }
+        return true, enc.emitMarshal(e, reflect.ValueOf(out), f)
+
+    case encoding.TextMarshaler:
+        text, err := v.MarshalText()
+        if err != nil {
+            return true, err
+        }
+        return true, enc.emitMarshalString(e, reflect.ValueOf(string(text)), f)
+    }
+
+    return false, nil
+}
+
+// the identity of a value that may be shared (pointers, maps and slices)
+type refKey struct {
+    ptr uintptr
+    t reflect.Type
+    n int           // slices of the same backing array differ in length
+}
+
+func refKeyOf(rv reflect.Value) (refKey, bool) {
+    switch rv.Kind() {
+    case reflect.Ptr, reflect.Map:
+        if rv.IsNil() {
+            return refKey{}, false
+        }
+        return refKey{ptr: rv.Pointer(), t: rv.Type()}, true
+
+    case reflect.Slice:
+        if rv.IsNil() || rv.Len() == 0 {
+            return refKey{}, false
+        }
+        return refKey{ptr: rv.Pointer(), t: rv.Type(), n: rv.Len()}, true
+    }
+    return refKey{}, false
+}
+
+// true if the value (or its address) has a custom marshaler
+func hasCustomMarshaler(rv reflect.Value) bool {
+    if !rv.CanInterface() {
+        return false
+    }
+    switch rv.Interface().(type) {
+    case Marshaler, encoding.TextMarshaler:
+        return true
+    }
+    if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+        switch rv.Addr().Interface().(type) {
+        case Marshaler, encoding.TextMarshaler:
+            return true
+        }
+    }
+    return false
+}
+
+// is aliasing of shared values in effect
+func (enc *Encoder) aliasing() bool {
+    return enc.opts.AliasMode == "anchor" && !enc.jsonOutput
+}
+
+// count the references to the shared values of a document
+func (enc *Encoder) scanRefs(rv reflect.Value) {
+
+    if !rv.IsValid() {
+        return
+    }
+
+    if k, ok := refKeyOf(rv); ok {
+        enc.refs[k]++
+        // seen before, the contents have been scanned (or are being)
+        if enc.refs[k] > 1 {
+            return
+        }
+    }
+
+    // custom marshalers produce new values, which are not followed
+    if enc.opts.Custom && hasCustomMarshaler(rv) {
+        return
+    }
+
+    // time values are scalars
+    if rv.Type() == timeType {
+        return
+    }
+
+    switch rv.Kind() {
+    case reflect.Interface, reflect.Ptr:
+        enc.scanRefs(rv.Elem())
+
+    case reflect.Map:
+        for _, key := range rv.MapKeys() {
+            enc.scanRefs(key)
+            enc.scanRefs(rv.MapIndex(key))
+        }
+
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < rv.Len(); i++ {
+            enc.scanRefs(rv.Index(i))
+        }
+
+    case reflect.Struct:
+        ti := enc.sc.LookupOrNewType(rv.Type())
+        if ti == nil {
+            return
+        }
+        for _, ff := range ti.fields {
+            if !ff.ignored {
+                enc.scanRefs(FieldByIndexRead(rv, ff.index))
+            }
+        }
+        if ti.inlineMap != nil {
+            enc.scanRefs(FieldByIndexRead(rv, ti.inlineMap.index))
+        }
+    }
+}
+
+// reset the anchor state for a new document with contents rv
+func (enc *Encoder) prepareRefs(rv reflect.Value) {
+    enc.refs = make(map[refKey]int)
+    enc.anchors = make(map[refKey]string)
+    enc.visiting = make(map[refKey]bool)
+    enc.anchorCount = 0
+    enc.anchor = ""
+    enc.pending = nil
+
+    if enc.aliasing() {
+        enc.scanRefs(rv)
+    }
+}
+
+// the anchor of the next node emitted (if any)
+func (enc *Encoder) takeAnchor() string {
+    anchor := enc.anchor
+    enc.anchor = ""
+    enc.pending = nil
+    return anchor
+}
+
+// f is the struct field the value belongs to (nil if none)
+func (enc *Encoder) emitMarshal(e *Emitter, rv reflect.Value, f *Field) error {
+
+    if !rv.IsValid() || rv.Kind() == reflect.Ptr && rv.IsNil() {
+        return enc.emitMarshalNull(e, rv)
+    }
+
+    // shared values are anchored on first use and aliased afterwards
+    if k, ok := refKeyOf(rv); ok {
+        if enc.aliasing() {
+            if enc.refs[k] > 1 {
+                if anchor, exists := enc.anchors[k]; exists {
+                    // the values waiting for an anchor (i.e. a shared pointer
+                    // to this) are the same node; alias them to it as well
+                    for _, pk := range enc.pending {
+                        enc.anchors[pk] = anchor
+                    }
+                    enc.anchor, enc.pending = "", nil
+                    return e.EmitEvent(Alias, anchor)
+                }
+                // a shared pointer to a shared value shares the anchor
+                if enc.anchor == "" {
+                    enc.anchorCount++
+                    enc.anchor = fmt.Sprintf("id%03d", enc.anchorCount)
+                }
+                enc.anchors[k] = enc.anchor
+                enc.pending = append(enc.pending, k)
+            }
+        } else {
+            if enc.visiting[k] {
+                return errors.New(fmt.Sprintf("cycle detected at value of type %s", rv.Type()))
+            }
+            enc.visiting[k] = true
+            defer delete(enc.visiting, k)
+        }
+    }
+
+    // time values are always native
+    switch rv.Type() {
+    case timeType:
+        return enc.emitMarshalTime(e, rv, f)
+    case durationType:
+        return enc.emitMarshalDuration(e, rv, f)
+    }
+
+    // custom marshalers take precedence
+    if enc.opts.Custom {
+        if handled, err := enc.emitMarshalCustom(e, rv, f); handled {
+            return err
+        }
     }
 
     switch rv.Kind() {
     case reflect.Interface, reflect.Ptr:
-        return enc.emitMarshal(e, rv.Elem())
+        return enc.emitMarshal(e, rv.Elem(), f)
     case reflect.Map:
-        return enc.emitMarshalMap(e, rv)
+        if isSetType(rv.Type()) {
+            return enc.emitMarshalSet(e, rv, f)
+        }
+        return enc.emitMarshalMap(e, rv, f)
     case reflect.Struct:
-        return enc.emitMarshalStruct(e, rv)
+        return enc.emitMarshalStruct(e, rv, f)
     case reflect.Slice, reflect.Array:
-        return enc.emitMarshalSlice(e, rv)
+        switch rv.Type() {
+        case mapSliceType:
+            return enc.emitMarshalMapSlice(e, rv, f)
+        case omapType:
+            return enc.emitMarshalPairs(e, rv, f, "!!omap")
+        case pairsType:
+            return enc.emitMarshalPairs(e, rv, f, "!!pairs")
+        }
+        if rv.Kind() == reflect.Slice && isBinaryType(rv.Type()) {
+            return enc.emitMarshalBinary(e, rv, f)
+        }
+        return enc.emitMarshalSlice(e, rv, f)
     case reflect.String:
-        return enc.emitMarshalString(e, rv)
+        return enc.emitMarshalString(e, rv, f)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-        return enc.emitMarshalInt(e, rv)
+        return enc.emitMarshalInt(e, rv, f)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-        return enc.emitMarshalUint(e, rv)
+        return enc.emitMarshalUint(e, rv, f)
 	case reflect.Float32, reflect.Float64:
-        return enc.emitMarshalFloat(e, rv)
+        return enc.emitMarshalFloat(e, rv, f)
     case reflect.Bool:
-        return enc.emitMarshalBool(e, rv)
+        return enc.emitMarshalBool(e, rv, f)
     }
 
     return errors.New("emit marshal can't handle type: " + rv.Type().String())
@@ -171,7 +811,8 @@ func (enc *Encoder) Marshal(v interface{}) ([]byte, error) {
     }
 
     // emit the document contents
-    err = enc.emitMarshal(e, reflect.ValueOf(v))
+    enc.prepareRefs(reflect.ValueOf(v))
+    err = enc.emitMarshal(e, reflect.ValueOf(v), nil)
     if err != nil {
         goto err_out
     }
@@ -196,6 +837,70 @@ err_out:
     return nil, err
 }
 
+// encode v as the next document of the stream
+// an error leaves the stream in an unknown state, so it is sticky
+func (enc *Encoder) Encode(v interface{}) error {
+
+    if enc.e == nil {
+        return errors.New("encoder is not bound to an output")
+    }
+
+    if enc.oerr != nil {
+        return enc.oerr
+    }
+
+    if err := enc.encodeDocument(v); err != nil {
+        enc.oerr = err
+        return err
+    }
+
+    enc.docs++
+
+    return nil
+}
+
+func (enc *Encoder) encodeDocument(v interface{}) error {
+
+    // only the first document may have an implicit start
+    if err := enc.emitEvent(DocumentStart, enc.docs == 0, "", nil); err != nil {
+        return err
+    }
+
+    enc.err = nil
+
+    // emit the document contents
+    enc.prepareRefs(reflect.ValueOf(v))
+    err := enc.emitMarshal(enc.e, reflect.ValueOf(v), nil)
+    if enc.err != nil {
+        err = enc.err
+        enc.err = nil
+    }
+    if err != nil {
+        return err
+    }
+
+    return enc.emitEvent(DocumentEnd, true)
+}
+
+// end the stream and release the emitter
+// after a failed Encode the stream is not ended, and the error is returned
+func (enc *Encoder) Close() error {
+
+    if enc.e == nil {
+        return errors.New("encoder is not bound to an output")
+    }
+
+    err := enc.oerr
+    if err == nil {
+        err = enc.emitEvent(StreamEnd)
+    }
+
+    enc.e.Destroy()
+    enc.e = nil
+
+    return err
+}
+
 func Marshal(v interface{}, opts...interface{}) ([]byte, error) {
 
     var err error
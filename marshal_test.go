@@ -0,0 +1,694 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// an encoding test; the output is decoded back to a value like back
+// (a pointer) and compared to the expected value
+type encodeTest struct {
+    name string
+    value interface{}
+    opts []interface{}
+    back interface{}
+    expected interface{}
+    fails bool
+    err error               // the error expected to be wrapped (if any)
+}
+
+func runEncodeTests(t *testing.T, tests []encodeTest) {
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value, tt.opts...)
+            if tt.err != nil {
+                if !errors.Is(err, tt.err) {
+                    t.Fatalf("expected %v, got %v", tt.err, err)
+                }
+                return
+            }
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got:\n%s", data)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            if err := Unmarshal(data, tt.back, tt.opts...); err != nil {
+                t.Fatalf("%v in:\n%s", err, data)
+            }
+
+            got := reflect.ValueOf(tt.back).Elem().Interface()
+            if !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %#v, expected %#v from:\n%s", got, tt.expected, data)
+            }
+        })
+    }
+}
+
+func TestMarshalStructTags(t *testing.T) {
+
+    value := tagged{Both: 1, JSON: 2, YAML: 3, None: 4, Skip: 5, Empty: 6}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "yaml tags",
+            value: value,
+            back: new(map[string]int),
+            expected: map[string]int{"y_both": 1, "j_only": 2, "y_only": 3, "None": 4, "Empty": 6},
+        }, {
+            name: "json tags first",
+            value: value,
+            opts: []interface{}{"struct-tag=json,yaml"},
+            back: new(map[string]int),
+            expected: map[string]int{"j_both": 1, "j_only": 2, "y_only": 3, "None": 4, "j_skip": 5, "j_empty": 6},
+        }, {
+            name: "round trip",
+            value: value,
+            back: new(tagged),
+            expected: tagged{Both: 1, JSON: 2, YAML: 3, None: 4, Empty: 6},
+        },
+    })
+}
+
+type flagged struct {
+    Name string `yaml:"name"`
+    Count int `yaml:"count,omitempty"`
+    Tags []string `yaml:"tags,omitempty"`
+    Next *flagged `yaml:"next,omitempty"`
+    Secret string `yaml:"-"`
+    ID int `yaml:"id,string"`
+    On bool `yaml:"on,string,omitempty"`
+    hidden int
+}
+
+func TestMarshalFieldFlags(t *testing.T) {
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "empty fields omitted",
+            value: flagged{Name: "a", Secret: "s", hidden: 1},
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{"name": "a", "id": "0"},
+        }, {
+            name: "set fields kept",
+            value: flagged{Name: "a", Count: 2, Tags: []string{"x"}, Next: &flagged{Name: "b"}, ID: 7, On: true},
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{
+                "name": "a", "count": 2, "tags": []interface{}{"x"}, "id": "7", "on": "true",
+                "next": map[interface{}]interface{}{"name": "b", "id": "0"},
+            },
+        }, {
+            name: "empty name kept",
+            value: flagged{},
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{"name": "", "id": "0"},
+        },
+    })
+}
+
+func TestMarshalEmbeddedFields(t *testing.T) {
+
+    var value embedding
+    value.ID = 1
+    value.Meta = &Meta{Kind: "hidden", Note: "n"}
+    value.Name = "x"
+    value.Spec.Size = 3
+    value.Rest = map[string]interface{}{"other": "o"}
+
+    conflict := value
+    conflict.Rest = map[string]interface{}{"name": "again"}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "flattened",
+            value: value,
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{"id": 1, "note": "n", "name": "x", "size": 3, "other": "o"},
+        }, {
+            name: "nil embedded pointer",
+            value: embedding{Name: "x"},
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{"id": 0, "name": "x", "size": 0},
+        }, {
+            name: "inline map key conflicts with a field",
+            value: conflict,
+            fails: true,
+        },
+    })
+}
+
+func TestMarshalCustom(t *testing.T) {
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "marshalers",
+            value: customized{Name: "ABC", Level: 2},
+            back: new(map[string]interface{}),
+            expected: map[string]interface{}{
+                "name": "abc", "level": "high",
+                "temp": map[interface{}]interface{}{"celsius": 0},
+            },
+        }, {
+            name: "round trip",
+            value: customized{Name: "ABC", Temp: temperature{21.5}, Level: 1},
+            back: new(customized),
+            expected: customized{Name: "ABC", Temp: temperature{21.5}, Level: 1},
+        }, {
+            name: "marshaler error",
+            value: []broken{{}},
+            err: errCustom,
+        }, {
+            name: "text marshaler error",
+            value: map[string]level{"a": 3},
+            err: errCustom,
+        },
+    })
+}
+
+type linked struct {
+    Name string `yaml:"name"`
+    Next *linked `yaml:"next,omitempty"`
+}
+
+type sharing struct {
+    A *container `yaml:"a"`
+    B *container `yaml:"b"`
+}
+
+func TestMarshalAnchors(t *testing.T) {
+
+    shared := &container{Name: "x", Image: "y"}
+    slice := []int{1, 2}
+
+    cycle := &linked{Name: "a"}
+    cycle.Next = &linked{Name: "b", Next: cycle}
+
+    tests := []struct {
+        name string
+        value interface{}
+        opts []interface{}
+        contains []string
+        excludes []string
+        fails bool
+    }{
+        {
+            name: "shared pointer",
+            value: sharing{A: shared, B: shared},
+            contains: []string{"&id001", "*id001"},
+        }, {
+            name: "shared slice",
+            value: map[string][]int{"a": slice, "b": slice},
+            contains: []string{"&id001", "*id001"},
+        }, {
+            name: "copies are not shared",
+            value: sharing{A: &container{Name: "x"}, B: &container{Name: "x"}},
+            excludes: []string{"&", "*"},
+        }, {
+            name: "cycle",
+            value: cycle,
+            contains: []string{"&id001", "*id001"},
+        }, {
+            name: "shared pointer without aliases",
+            value: sharing{A: shared, B: shared},
+            opts: []interface{}{"alias-mode=error"},
+            excludes: []string{"&", "*"},
+        }, {
+            name: "shared pointer in json",
+            value: sharing{A: shared, B: shared},
+            opts: []interface{}{"output-mode=json"},
+            excludes: []string{"&", "*"},
+        }, {
+            name: "cycle without aliases",
+            value: cycle,
+            opts: []interface{}{"alias-mode=error"},
+            fails: true,
+        }, {
+            name: "cycle in json",
+            value: cycle,
+            opts: []interface{}{"output-mode=json"},
+            fails: true,
+        }, {
+            name: "bad alias mode",
+            value: 1,
+            opts: []interface{}{"alias-mode=copy"},
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value, tt.opts...)
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got:\n%s", data)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            for _, s := range tt.contains {
+                if !strings.Contains(string(data), s) {
+                    t.Errorf("%q missing from:\n%s", s, data)
+                }
+            }
+            for _, s := range tt.excludes {
+                if strings.Contains(string(data), s) {
+                    t.Errorf("unexpected %q in:\n%s", s, data)
+                }
+            }
+        })
+    }
+}
+
+func TestMarshalAnchorsRoundTrip(t *testing.T) {
+
+    shared := &container{Name: "x", Image: "y"}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "shared pointer",
+            value: sharing{A: shared, B: shared},
+            back: new(sharing),
+            expected: sharing{A: &container{"x", "y"}, B: &container{"x", "y"}},
+        }, {
+            name: "without aliases",
+            value: sharing{A: shared, B: shared},
+            opts: []interface{}{"alias-mode=error"},
+            back: new(sharing),
+            expected: sharing{A: &container{"x", "y"}, B: &container{"x", "y"}},
+        },
+    })
+}
+
+func TestNaturalKeyLess(t *testing.T) {
+
+    tests := []struct {
+        a, b interface{}
+        less bool
+    }{
+        { nil, false, true },
+        { false, true, true },
+        { true, false, false },
+        { true, 0, true },
+        { 9, 10, true },
+        { 10, 9, false },
+        { -1, uint(0), true },
+        { uint(1), -1, false },
+        { uint64(18446744073709551615), 1, false },
+        { 1, 1.5, true },
+        { 2.5, 2, false },
+        { 100, "1", true },
+        { "a10", "a9", true },
+        { "b", "a", false },
+        { "z", []int{1}, true },
+        { "a", "a", false },
+        { 1, 1, false },
+        // equal in the natural order, by type then by contents
+        { 1.0, 1, true },
+        { 1, 1.0, false },
+        { 1, int8(1), true },
+        { [1]interface{}{1}, [1]interface{}{"1"}, true },
+        { [1]interface{}{1.0}, [1]interface{}{1}, true },
+        { keyPoint{1, 10}, keyPoint{1, 2}, true },
+    }
+
+    for _, tt := range tests {
+        if less := NaturalKeyLess(tt.a, tt.b); less != tt.less {
+            t.Errorf("%#v < %#v: got %v, expected %v", tt.a, tt.b, less, tt.less)
+        }
+    }
+
+    // a total order; of two different keys exactly one goes first
+    keys := []interface{}{
+        nil, false, true, -1, 0, 1, 1.0, int8(1), uint(1), 1.5, "1", "a",
+        [1]interface{}{1}, [1]interface{}{"1"}, [1]interface{}{1.0},
+        keyPoint{1, 2}, keyPoint{1, 10}, keyPoint{2, 1},
+    }
+    for i, a := range keys {
+        for j, b := range keys {
+            ab, ba := NaturalKeyLess(a, b), NaturalKeyLess(b, a)
+            if i == j && (ab || ba) || i != j && ab == ba {
+                t.Errorf("%#v, %#v: got %v and %v", a, b, ab, ba)
+            }
+        }
+    }
+}
+
+// true if the keys appear in order in the text
+func keysInOrder(text string, keys []string) bool {
+    at := 0
+    for _, key := range keys {
+        i := strings.Index(text[at:], key + ":")
+        if i < 0 {
+            return false
+        }
+        at += i + len(key)
+    }
+    return true
+}
+
+func TestMarshalKeyOrder(t *testing.T) {
+
+    reverse := OptionsDefault
+    reverse.MapKeyLess = func(a, b interface{}) bool {
+        return NaturalKeyLess(b, a)
+    }
+
+    tests := []struct {
+        name string
+        value interface{}
+        opts []interface{}
+        keys []string
+    }{
+        {
+            name: "strings",
+            value: map[string]int{"c": 1, "a": 2, "b": 3},
+            keys: []string{"a", "b", "c"},
+        }, {
+            name: "numbers",
+            value: map[int]int{100: 1, 9: 2, 10: 3, -1: 4},
+            keys: []string{"-1", "9", "10", "100"},
+        }, {
+            name: "mixed",
+            value: map[interface{}]int{"x": 1, 2: 2, true: 3},
+            keys: []string{"true", "2", "x"},
+        }, {
+            name: "nested",
+            value: map[string]interface{}{"b": map[string]int{"y": 1, "x": 2}, "a": 0},
+            keys: []string{"a", "b", "x", "y"},
+        }, {
+            name: "equal in the natural order",
+            value: map[interface{}]string{1: "a", 1.0: "b", int8(1): "c", uint(1): "d"},
+            keys: []string{"1", "1", "1", "1"},
+        }, {
+            name: "custom order",
+            value: map[int]int{1: 1, 2: 2, 3: 3},
+            opts: []interface{}{reverse},
+            keys: []string{"3", "2", "1"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            first, err := Marshal(tt.value, tt.opts...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !keysInOrder(string(first), tt.keys) {
+                t.Fatalf("expected keys %v in order in:\n%s", tt.keys, first)
+            }
+
+            // and the same every time
+            for i := 0; i < 20; i++ {
+                data, err := Marshal(tt.value, tt.opts...)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if string(data) != string(first) {
+                    t.Fatalf("got:\n%s\nexpected:\n%s", data, first)
+                }
+            }
+        })
+    }
+}
+
+func TestNeedsQuoting(t *testing.T) {
+
+    tests := []struct {
+        schema string
+        strs []string
+        quoted bool
+    }{
+        {
+            schema: "core",
+            strs: []string{"true", "True", "false", "null", "~", "", "123", "-1", "0x1F", "0o17", "1e3", ".inf", ".nan", "3.14"},
+            quoted: true,
+        }, {
+            schema: "core",
+            strs: []string{"hello", "yes", "on", "1_000", "2001-12-14", "0b101", "a b"},
+            quoted: false,
+        }, {
+            schema: "1.1",
+            strs: []string{"yes", "No", "on", "off", "y", "true", "null", "1_000", "0x1F", "017", "190:20:30", "2001-12-14", "1.5"},
+            quoted: true,
+        }, {
+            schema: "1.1",
+            strs: []string{"hello", "yesno", "0o17", "a b"},
+            quoted: false,
+        }, {
+            schema: "json",
+            strs: []string{"true", "false", "null", "123", "-1", "1e3", "3.14"},
+            quoted: true,
+        }, {
+            schema: "json",
+            strs: []string{"hello", "True", "yes", "~", "0x1F", ".inf"},
+            quoted: false,
+        },
+    }
+
+    for _, tt := range tests {
+        enc := &Encoder{si: LookupSchema(tt.schema), opts: &OptionsDefault}
+        for _, str := range tt.strs {
+            if quoted := enc.needsQuoting(str); quoted != tt.quoted {
+                t.Errorf("%s %q: got %v, expected %v", tt.schema, str, quoted, tt.quoted)
+            }
+        }
+    }
+}
+
+func TestMarshalStringQuoting(t *testing.T) {
+
+    strs := []string{
+        "true", "null", "~", "", "123", "0x1F", "1e3", ".inf", "yes", "on",
+        "1_000", "2001-12-14", "12:30:45", "hello", "- dash", "a: b", "#hash",
+    }
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "core",
+            value: strs,
+            back: new([]interface{}),
+            expected: stringsToGeneric(strs),
+        }, {
+            name: "1.1",
+            value: strs,
+            opts: []interface{}{"schema=1.1"},
+            back: new([]interface{}),
+            expected: stringsToGeneric(strs),
+        }, {
+            name: "json",
+            value: strs,
+            opts: []interface{}{"schema=json"},
+            back: new([]interface{}),
+            expected: stringsToGeneric(strs),
+        }, {
+            name: "keys",
+            value: map[string]string{"true": "1", "null": "~", "y": "n"},
+            opts: []interface{}{"schema=1.1"},
+            back: new(map[interface{}]interface{}),
+            expected: map[interface{}]interface{}{"true": "1", "null": "~", "y": "n"},
+        },
+    })
+}
+
+func stringsToGeneric(strs []string) []interface{} {
+    v := make([]interface{}, len(strs))
+    for i, str := range strs {
+        v[i] = str
+    }
+    return v
+}
+
+func TestDecodeImplicitNumbers(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "hex",
+            input: "[0x1F, 0xff, 0x]\n",
+            into: new([]interface{}),
+            expected: []interface{}{31, 255, "0x"},
+        }, {
+            name: "exponents",
+            input: "[1e3, 2E2, 1_000, 2x3, 1a5]\n",
+            into: new([]interface{}),
+            expected: []interface{}{1000.0, 200.0, "1_000", "2x3", "1a5"},
+        },
+    })
+}
+
+func TestBlockScalarStyle(t *testing.T) {
+
+    prose := strings.Repeat("lorem ipsum ", 10) + "\nend"
+
+    tests := []struct {
+        name string
+        str string
+        width int
+        style ScalarStyle
+    }{
+        { name: "single line", str: "abc", style: Any },
+        { name: "multiline", str: "a\nb\n", style: Literal },
+        { name: "no final newline", str: "a\nb", style: Literal },
+        { name: "tabs", str: "a\tb\nc", style: Literal },
+        { name: "long prose", str: prose, style: Folded },
+        { name: "long prose in a wide output", str: prose, width: 200, style: Literal },
+        { name: "long word", str: strings.Repeat("x", 100) + "\ny", style: Literal },
+        { name: "control character", str: "a\x01\nb", style: Any },
+    }
+
+    for _, tt := range tests {
+        o := OptionsDefault
+        o.Width = tt.width
+        enc := &Encoder{opts: &o}
+        if style := enc.blockScalarStyle(tt.str); style != tt.style {
+            t.Errorf("%s: got %v, expected %v", tt.name, style, tt.style)
+        }
+    }
+}
+
+type scripted struct {
+    Script string `yaml:"script"`
+    Literal string `yaml:"literal,literal"`
+    Folded string `yaml:"folded,folded"`
+    Quoted string `yaml:"quoted,quoted"`
+}
+
+func TestMarshalMultiline(t *testing.T) {
+
+    value := scripted{
+        Script: "#!/bin/sh\necho hello\n",
+        Literal: "one line",
+        Folded: "some text\nmore text\n",
+        Quoted: "a\nb\n",
+    }
+
+    tests := []struct {
+        name string
+        value interface{}
+        opts []interface{}
+        contains []string
+        excludes []string
+    }{
+        {
+            name: "literal block",
+            value: map[string]string{"s": "a\nb\n"},
+            contains: []string{"s: |"},
+        }, {
+            name: "field hints",
+            value: value,
+            contains: []string{"script: |", "literal: |", "folded: >", `quoted: "`},
+        }, {
+            name: "flow output",
+            value: map[string]string{"s": "a\nb\n"},
+            opts: []interface{}{"output-mode=flow"},
+            excludes: []string{"|", ">"},
+        }, {
+            name: "json output",
+            value: map[string]string{"s": "a\nb\n"},
+            opts: []interface{}{"output-mode=json"},
+            contains: []string{`"a\nb\n"`},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            data, err := Marshal(tt.value, tt.opts...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, s := range tt.contains {
+                if !strings.Contains(string(data), s) {
+                    t.Errorf("%q missing from:\n%s", s, data)
+                }
+            }
+            for _, s := range tt.excludes {
+                if strings.Contains(string(data), s) {
+                    t.Errorf("unexpected %q in:\n%s", s, data)
+                }
+            }
+        })
+    }
+
+    strs := []string{
+        "a\nb", "a\nb\n", "a\nb\n\n", "\n", "  indented\nx\n", "x\n  indented\n",
+        "trailing \nspace", "tab\there\n", "a\x01\nb",
+        strings.Repeat("lorem ipsum ", 10) + "\nend\n",
+    }
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "round trip",
+            value: strs,
+            back: new([]string),
+            expected: strs,
+        }, {
+            name: "round trip with hints",
+            value: value,
+            back: new(scripted),
+            expected: value,
+        },
+    })
+}
+
+type sharedMaps struct {
+    A *map[string]int `yaml:"a"`
+    B *map[string]int `yaml:"b"`
+    C map[string]int `yaml:"c"`
+}
+
+type sharedSlices struct {
+    A **[]int `yaml:"a"`
+    B **[]int `yaml:"b"`
+    C *[]int `yaml:"c"`
+}
+
+func TestMarshalSharedPointees(t *testing.T) {
+
+    m := map[string]int{"x": 1}
+    s := []int{1, 2}
+    ps := &s
+
+    // the pointee was anchored first
+    first := []interface{}{m, &m, &m}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "shared pointer to a shared map",
+            value: sharedMaps{A: &m, B: &m, C: m},
+            back: new(sharedMaps),
+            expected: sharedMaps{A: &m, B: &m, C: m},
+        }, {
+            name: "shared pointer to a shared pointer to a shared slice",
+            value: sharedSlices{A: &ps, B: &ps, C: ps},
+            back: new(sharedSlices),
+            expected: sharedSlices{A: &ps, B: &ps, C: ps},
+        }, {
+            name: "shared pointee anchored first",
+            value: first,
+            back: new([]map[string]int),
+            expected: []map[string]int{m, m, m},
+        },
+    })
+
+    data, err := Marshal(sharedMaps{A: &m, B: &m, C: m})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if n := strings.Count(string(data), "&"); n != 1 {
+        t.Errorf("expected a single anchor, got %d in:\n%s", n, data)
+    }
+}
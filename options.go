@@ -20,6 +20,17 @@ type Options struct {
     Lazy, Verbose, Debug bool   // parser options
     Strict, Custom bool         // unmarshal options
     Schema string               // auto, failsafe, yaml, json, 1.1, 1.2, 1.3
+    StructTag string            // struct tags to use, in order of precedence (comma separated)
+    Path string                 // decode only the node at this path (i.e. /spec/containers/0)
+    SharePointers bool          // aliases to pointers share the anchored object
+    MergeKeys bool              // merge keys (<<) for the core and 1.3 schemas (always on for 1.1)
+    OrderedMaps bool            // generic mappings decode to MapSlice (in document order, always for a MapSlice root)
+    Timestamps bool             // implicit timestamps for the core and 1.3 schemas (always on for 1.1)
+
+    MaxDepth int                // maximum nesting depth of a document, 0 unlimited
+    MaxAliasExpansion int       // maximum number of nodes expanded by aliases in a document, 0 unlimited
+    MaxNodes int                // maximum number of nodes in a document, 0 unlimited
+    MaxInputBytes int           // maximum size of the input, 0 unlimited
 
     Indent int                  // emitter indent - 1 >= i <= 9 set, 0 default
     Width int                   // 0 = default, 80 >= w < 255 set, < 0 inf
@@ -31,6 +42,8 @@ type Options struct {
     OutputMode string           // original, block, flow, flow-oneline, json, json-oneline, dejson, pretty
     VersionDirectives string    // auto, off, on
     TagDirectives string        // auto, off, on
+    AliasMode string            // anchor (shared values as aliases), error (error on cycles)
+    MapKeyLess func(a, b interface{}) bool // the order of map keys, nil for NaturalKeyLess
 }
 
 var OptionsDefault = Options {
@@ -48,6 +61,17 @@ var OptionsDefault = Options {
     Custom: true,               // by default we have custom unmarshalers
     SearchPath: "",             // by default just the current dir
     Schema: "auto",             // by default autodetect
+    StructTag: "yaml,json",     // by default yaml tags, then json tags
+    Path: "",                   // by default the whole document
+    SharePointers: false,       // by default aliases are copies
+    MergeKeys: false,           // by default merge keys are 1.1 only
+    OrderedMaps: false,         // by default generic mappings are maps
+    Timestamps: false,          // by default implicit timestamps are 1.1 only
+
+    MaxDepth: 0,                // by default no limits
+    MaxAliasExpansion: 0,
+    MaxNodes: 0,
+    MaxInputBytes: 0,
 
     Indent: 0,                  // use the library default,
     Width: 0,                   // use the library default,
@@ -59,6 +83,8 @@ var OptionsDefault = Options {
     OutputMode: "",             // use the library default
     VersionDirectives: "auto",  // use the library default
     TagDirectives: "auto",      // use the library default
+    AliasMode: "anchor",        // by default shared values are aliased
+    MapKeyLess: nil,            // by default the natural order
 }
 
 func GetOptions(opts []interface{}) (*Options, error) {
@@ -141,6 +167,14 @@ func GetOptions(opts []interface{}) (*Options, error) {
             o.Strict = set
         } else if strings.EqualFold(key, "custom") {
             o.Custom = set
+        } else if strings.EqualFold(key, "share-pointers") {
+            o.SharePointers = set
+        } else if strings.EqualFold(key, "merge-keys") {
+            o.MergeKeys = set
+        } else if strings.EqualFold(key, "ordered-maps") {
+            o.OrderedMaps = set
+        } else if strings.EqualFold(key, "timestamps") {
+            o.Timestamps = set
 
         } else if !neg && strings.EqualFold(key, "version") {
 
@@ -173,6 +207,34 @@ func GetOptions(opts []interface{}) (*Options, error) {
                 return nil, errors.New(fmt.Sprintf("Bad schema %s (must be one of auto, failsafe, core, json, 1.1, 1.2, 1.3)", value))
             }
 
+        } else if !neg && strings.EqualFold(key, "struct-tag") {
+
+            if strings.TrimSpace(value) == "" {
+                return nil, errors.New(fmt.Sprintf("Bad struct-tag %s (must be a comma separated list of tags)", value))
+            }
+            o.StructTag = value
+
+        } else if !neg && strings.EqualFold(key, "path") {
+
+            o.Path = value
+
+        } else if !neg && (strings.EqualFold(key, "max-depth") || strings.EqualFold(key, "max-alias-expansion") ||
+                           strings.EqualFold(key, "max-nodes") || strings.EqualFold(key, "max-input-bytes")) {
+            i, err := strconv.ParseInt(value, 10, 64)
+            if err != nil || i < 0 {
+                return nil, errors.New(fmt.Sprintf("Bad %s %s format", key, value))
+            }
+            switch strings.ToLower(key) {
+            case "max-depth":
+                o.MaxDepth = int(i)
+            case "max-alias-expansion":
+                o.MaxAliasExpansion = int(i)
+            case "max-nodes":
+                o.MaxNodes = int(i)
+            case "max-input-bytes":
+                o.MaxInputBytes = int(i)
+            }
+
         } else if !neg && strings.EqualFold(key, "indent") {
             i, err := strconv.ParseInt(value, 10, 64)
             if err != nil || i < 2 || i > 9 {
@@ -221,9 +283,43 @@ func GetOptions(opts []interface{}) (*Options, error) {
             default:
                 return nil, errors.New(fmt.Sprintf("Bad tag-directives %s (must be one of auto, off, on)", value))
             }
+        } else if !neg && strings.EqualFold(key, "alias-mode") {
+            switch value {
+            case "anchor", "error":
+                o.AliasMode = value
+            default:
+                return nil, errors.New(fmt.Sprintf("Bad alias-mode %s (must be one of anchor, error)", value))
+            }
         } else {
             return nil, errors.New(fmt.Sprintf("Unknown Option %s", opt))
         }
     }
     return &o, nil
 }
+
+// the struct tags to use in order of precedence
+func (o *Options) StructTags() []string {
+    tags := make([]string, 0)
+    for _, tag := range strings.Split(o.StructTag, ",") {
+        if tag = strings.TrimSpace(tag); tag != "" {
+            tags = append(tags, tag)
+        }
+    }
+    return tags
+}
+
+type OptionsProvider interface {
+    Options() *Options
+}
+
+// the options of an object, or the defaults if it doesn't provide them
+func GetObjectOptions(i interface{}) *Options {
+    if i != nil {
+        op, hasOp := i.(OptionsProvider)
+        if hasOp {
+            return op.Options()
+        }
+    }
+    o := OptionsDefault
+    return &o
+}
@@ -0,0 +1,76 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "fmt"
+    "unsafe"
+    "errors"
+)
+
+/*
+#cgo pkg-config: libfyaml
+#include "callback.h"
+*/
+import "C"
+
+// the nodes matching the YPATH expression, starting from this node
+func (n *Node) Query(ypath string) ([]*Node, error) {
+
+    // the path parse errors are collected
+    d, err := DiagCreate()
+    if err != nil {
+        return nil, err
+    }
+    defer d.Destroy()
+
+    cstr := C.CString(ypath)
+    defer C.free(unsafe.Pointer(cstr))
+
+    var pcfg C.struct_fy_path_parse_cfg
+    pcfg.diag = d.C()
+
+    expr := C.fy_path_expr_build_from_string(&pcfg, cstr, C.size_t(len(ypath)))
+    if expr == nil {
+        if dl := d.Diagnostics(ypath); len(dl) > 0 {
+            return nil, dl
+        }
+        return nil, errors.New(fmt.Sprintf("Bad path expression %s", ypath))
+    }
+    defer C.fy_path_expr_free(expr)
+
+    var xcfg C.struct_fy_path_exec_cfg
+    xcfg.diag = d.C()
+
+    fypx := C.fy_path_exec_create(&xcfg)
+    if fypx == nil {
+        return nil, errors.New("Failed to create path executor")
+    }
+    defer C.fy_path_exec_destroy(fypx)
+
+    if rc := C.fy_path_exec_execute(fypx, expr, n.C()); rc != 0 {
+        return nil, errors.New(fmt.Sprintf("Failed to execute path expression %s", ypath))
+    }
+
+    // the results are nodes of the document, so they outlive the executor
+    var nodes []*Node = nil
+    var prevp unsafe.Pointer = nil
+
+    for {
+        fyn := C.fy_path_exec_results_iterate(fypx, &prevp)
+        if fyn == nil {
+            break
+        }
+        nodes = append(nodes, (*Node)(fyn))
+    }
+
+    return nodes, nil
+}
+
+// the nodes matching the YPATH expression, starting from the root
+func (d *Document) Query(ypath string) ([]*Node, error) {
+    root := d.Root()
+    if root == nil {
+        return nil, nil
+    }
+    return root.Query(ypath)
+}
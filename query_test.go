@@ -0,0 +1,203 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "io"
+    "reflect"
+    "testing"
+)
+
+const queryInput = `
+spec:
+  containers:
+    - name: web
+      image: nginx
+    - name: db
+      image: postgres
+  replicas: 3
+`
+
+func TestQuery(t *testing.T) {
+
+    tests := []struct {
+        name string
+        ypath string
+        values []string
+        fails bool
+    }{
+        {
+            name: "scalar",
+            ypath: "/spec/replicas",
+            values: []string{"3"},
+        }, {
+            name: "sequence index",
+            ypath: "/spec/containers/1/name",
+            values: []string{"db"},
+        }, {
+            name: "missing",
+            ypath: "/spec/volumes",
+            values: nil,
+        }, {
+            name: "bad expression",
+            ypath: "/spec/[",
+            fails: true,
+        },
+    }
+
+    d, err := ParseDocument([]byte(queryInput))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer d.Destroy()
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            nodes, err := d.Query(tt.ypath)
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got %v", nodes)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var values []string
+            for _, n := range nodes {
+                values = append(values, n.ScalarValue())
+            }
+            if !reflect.DeepEqual(values, tt.values) {
+                t.Errorf("got %q, expected %q", values, tt.values)
+            }
+        })
+    }
+
+    // relative to a node
+    spec := d.Root().MappingLookup("spec")
+    nodes, err := spec.Query("containers/0/image")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(nodes) != 1 || nodes[0].ScalarValue() != "nginx" {
+        t.Errorf("unexpected result %v", nodes)
+    }
+}
+
+type container struct {
+    Name string `yaml:"name"`
+    Image string `yaml:"image"`
+}
+
+func TestDecodePath(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "mapping",
+            input: queryInput,
+            opts: []interface{}{"path=/spec/containers/0"},
+            into: new(container),
+            expected: container{Name: "web", Image: "nginx"},
+        }, {
+            name: "sequence",
+            input: queryInput,
+            opts: []interface{}{"path=/spec/containers"},
+            into: new([]container),
+            expected: []container{{"web", "nginx"}, {"db", "postgres"}},
+        }, {
+            name: "scalar",
+            input: queryInput,
+            opts: []interface{}{"path=/spec/replicas"},
+            into: new(int),
+            expected: 3,
+        }, {
+            name: "without the leading slash",
+            input: queryInput,
+            opts: []interface{}{"path=spec/containers/1/name"},
+            into: new(string),
+            expected: "db",
+        }, {
+            name: "root",
+            input: "a: 1\n",
+            opts: []interface{}{"path=/"},
+            into: new(map[string]int),
+            expected: map[string]int{"a": 1},
+        }, {
+            name: "alias to an anchor outside of the path",
+            input: "defaults: &d {image: busybox}\nspec: {name: x, image: *d}\n",
+            opts: []interface{}{"path=/spec/image"},
+            into: new(map[string]string),
+            expected: map[string]string{"image": "busybox"},
+        }, {
+            name: "merge keys outside of the path",
+            input: "base: &b {image: busybox}\nspec:\n  <<: *b\n  name: x\n",
+            opts: []interface{}{"path=/spec", "merge-keys"},
+            into: new(container),
+            expected: container{Name: "x", Image: "busybox"},
+        }, {
+            name: "version of the document is kept",
+            input: "%YAML 1.1\n---\nspec: {enabled: yes}\n",
+            opts: []interface{}{"path=/spec/enabled"},
+            into: new(interface{}),
+            expected: true,
+        }, {
+            name: "keys are not the path",
+            input: "? spec\n: 1\n",
+            opts: []interface{}{"path=/spec"},
+            into: new(int),
+            expected: 1,
+        }, {
+            name: "not found",
+            input: queryInput,
+            opts: []interface{}{"path=/spec/volumes"},
+            into: new(interface{}),
+            fails: true,
+        }, {
+            name: "type mismatch at the path",
+            input: queryInput,
+            opts: []interface{}{"path=/spec/containers"},
+            into: new(container),
+            fails: true,
+        },
+    })
+}
+
+func TestDecodePathStream(t *testing.T) {
+
+    dec, err := NewDataDecoder([]byte("--- {a: 1}\n--- {b: 2}\n--- {a: 3}\n"), "path=/a")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer dec.Destroy()
+
+    // the second document is missing the path, but the rest are fine
+    expected := []struct {
+        value int
+        fails bool
+    }{
+        { value: 1 }, { fails: true }, { value: 3 },
+    }
+
+    for i, e := range expected {
+        var v int
+        err := dec.Decode(&v)
+        if e.fails {
+            if err == nil {
+                t.Fatalf("document %d: expected an error, got %d", i, v)
+            }
+            continue
+        }
+        if err != nil {
+            t.Fatalf("document %d: %v", i, err)
+        }
+        if v != e.value {
+            t.Errorf("document %d: got %d, expected %d", i, v, e.value)
+        }
+    }
+
+    var v int
+    if err := dec.Decode(&v); err != io.EOF {
+        t.Errorf("expected EOF, got %v", err)
+    }
+}
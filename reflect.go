@@ -41,6 +41,7 @@ type RootState struct {
     rv *reflect.Value       // root reflect value
     sc *StructCache         // the cache of the decoded structs
     si SchemaImplementer    // our schema (if it exists)
+    opts *Options           // the decoder options
 
     anchors map[string]*ResolverEntry
 }
@@ -52,9 +53,17 @@ func NewRootState(event *Event, path *Path, root interface{}, si SchemaImplement
         sc: NewStructCache(dp),
         si: si,
         dp: dp,
+        opts: GetObjectOptions(dp),
         anchors: make(map[string]*ResolverEntry),
     }
     s.startRv = &s.startRvt
+
+    // an ordered root orders the nested generic mappings too
+    if t := reflect.TypeOf(root); t != nil && t.Kind() == reflect.Ptr && t.Elem() == mapSliceType && !s.opts.OrderedMaps {
+        o := *s.opts
+        o.OrderedMaps = true
+        s.opts = &o
+    }
     return s, nil
 }
 
@@ -72,6 +81,7 @@ func NewMappingStateDefault(event *Event, path *Path, startRv *reflect.Value, t
         t: t,
         anchor: event.AnchorString(),
         dupf: make(map[*Field]uvoid),
+        dupk: make(map[string]uvoid),
     }, nil
 }
 
@@ -127,6 +137,11 @@ func (s *RootState) FindReference(anchor string) (ObjectWrapper, CollectionWrapp
     return nil, nil, nil, nil
 }
 
+// implement the OptionsProvider interface
+func (s *RootState) Options() *Options {
+    return s.opts
+}
+
 // implement the DebugfProvider interface
 func (s *RootState) Debugf(format string, a ...interface{}) {
     s.dp.Debugf(format, a...)
@@ -231,6 +246,54 @@ func (s *RootState) CurrentAddress(path *Path) AddressWrapper {
     return nil
 }
 
+// the parent of the anchored nodes outside of the path option subtree
+// they are decoded to generic values, only so that aliases can refer to them
+type DetachedState struct {
+}
+
+func (s *DetachedState) StartRV() *reflect.Value {
+    return nil
+}
+
+func (s *DetachedState) Anchor() *string {
+    return nil
+}
+
+func (s *DetachedState) TagHandler() TagHandler {
+    return nil
+}
+
+func (s *DetachedState) SchemaImplementer() SchemaImplementer {
+    return nil
+}
+
+func (s *DetachedState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, error) {
+    rv := reflect.New(genericIfaceType).Elem()
+    soc := path.RootUserData().(SchemaObjectCreator)
+    return soc.NewSchemaObject(event, path, &rv)
+}
+
+func (s *DetachedState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) error {
+    if anchor := ow.Anchor(); anchor != nil {
+        if r, hasR := path.RootUserData().(Resolver); hasR {
+            return r.RegisterAnchor(*anchor, path, ow, s, nil)
+        }
+    }
+    return nil
+}
+
+func (s *DetachedState) CollectionStart(event *Event, path *Path) error {
+    return nil
+}
+
+func (s *DetachedState) CollectionEnd(event *Event, path *Path) error {
+    return nil
+}
+
+func (s *DetachedState) CurrentAddress(path *Path) AddressWrapper {
+    return nil
+}
+
 type SequenceState struct {
     startRv *reflect.Value  // the start reflection value
     t TagHandler
@@ -272,7 +335,7 @@ func (s *SequenceState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, err
 
     s.rvi = &rvt
 
-    rv, err := IndirectPointer(s.rvi)
+    rv, err := IndirectPointerUnlessAlias(event, s.rvi)
     if err != nil {
         return nil, err
     }
@@ -332,6 +395,15 @@ func (s *SequenceState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) err
         // rvt.Set(*s.rvi)
         rvt.Set(*s.ow.StartRV())
 
+    case reflect.Array:
+
+        // arrays are fixed size
+        if s.idx >= s.rv.Len() {
+            return errors.New(fmt.Sprintf("%v: index %d out of range for array of length %d", path, s.idx, s.rv.Len()))
+        }
+
+        s.rv.Index(s.idx).Set(*s.ow.StartRV())
+
     case reflect.Interface:
         panic("")
 
@@ -369,6 +441,10 @@ func (s *SequenceState) CollectionStart(event *Event, path *Path) error {
         // start by resetting the slice length to zero
         rv.SetLen(0)
 
+    case reflect.Array:
+        // start with a zeroed array
+        rv.Set(reflect.Zero(rv.Type()))
+
     case reflect.Interface:
         // save interface
         ri = rv
@@ -490,6 +566,13 @@ type MappingState struct {
     ti *TypeInfo            // the type-info of the struct (if is a struct)
     uf *Field               // the unmarshaler field if on struct
     dupf map[*Field]uvoid   // duplicate fields check
+    inlineKey *string       // the key when storing to the inline map
+    dupk map[string]uvoid   // duplicate inline map keys check
+    ordered bool            // an ordered mapping (a MapSlice)
+    dupo map[interface{}]uvoid // duplicate keys check of ordered mappings
+    dupm map[interface{}]uvoid // duplicate keys check of maps (the existing keys are overwritten)
+    inMerge bool            // the current key/value is a merge key
+    merges []reflect.Value  // the values of the merge keys (merged at the end)
 
     ow ObjectWrapper        // the current object addressed
     owk ObjectWrapper       // the key object wrapper
@@ -497,11 +580,12 @@ type MappingState struct {
 
 }
 
-func (s *MappingState) ObjStartInMapKeyTyped(path *Path) (*reflect.Value, error) {
+func (s *MappingState) ObjStartInMapKeyTyped(event *Event, path *Path) (*reflect.Value, error) {
 
+    // struct fields are addressed by name only
     scalarKey := s.pc.MappingScalarKey()
-    if scalarKey == nil {
-        panic("Mapping scalar key is NULL, can't handle complex key yet\n")
+    if event.Type() != Scalar || scalarKey == nil {
+        return nil, errors.New(fmt.Sprintf("%v: complex key not allowed for %s", path, s.rv.Type()))
     }
 
     // in typed mode, the tag is ignored...
@@ -510,17 +594,50 @@ func (s *MappingState) ObjStartInMapKeyTyped(path *Path) (*reflect.Value, error)
     strkey := scalarKey.Text()
 
     // typed mapping
-    rvv, uf := s.ti.FieldByName(strkey, s.rv)
-    if rvv == nil {
-        return nil, errors.New(fmt.Sprintf("%v: illegal key field %s", path, strkey))
+    rvv, uf, err := s.ti.FieldByName(strkey, s.rv)
+    if err != nil {
+        return nil, fmt.Errorf("%v: %w", path, err)
     }
 
-    // check for duplicate
-    if _, exists := s.dupf[uf]; exists {
-        return nil, errors.New(fmt.Sprintf("%v: duplicate key %s", path, strkey))
+    s.inlineKey = nil
+
+    if rvv != nil {
+
+        // check for duplicate
+        if _, exists := s.dupf[uf]; exists {
+            return nil, errors.New(fmt.Sprintf("%v: duplicate key %s", path, strkey))
+        }
+        // mark it
+        s.dupf[uf]=uvoid{}
+
+    } else if s.ti.inlineMap != nil {
+
+        // no field matched, it goes to the inline map
+        if _, exists := s.dupk[strkey]; exists {
+            return nil, errors.New(fmt.Sprintf("%v: duplicate key %s", path, strkey))
+        }
+        s.dupk[strkey]=uvoid{}
+
+        // the value is stored to the map at the end
+        uf = s.ti.inlineMap
+        mt := uf.Type(s.ti.t)
+        rvt := reflect.New(mt.Elem()).Elem()
+        rvv = &rvt
+
+        key := strkey
+        s.inlineKey = &key
+
+        // the key is decoded to the key type of the map
+        rvk := reflect.New(mt.Key()).Elem()
+        s.rvk = &rvk
+        s.uf = uf
+        s.rvv = rvv
+
+        return IndirectPointer(s.rvk)
+
+    } else {
+        return nil, errors.New(fmt.Sprintf("%v: illegal key field %s", path, strkey))
     }
-    // mark it
-    s.dupf[uf]=uvoid{}
 
     // get the pointer to the reflect value of the string key
     rvt := reflect.ValueOf(&strkey).Elem()
@@ -546,24 +663,41 @@ func (s *MappingState) ObjEndInMapKeyTyped(event *Event, path *Path) error {
 
 func (s *MappingState) ObjStartInMapValueTyped(event *Event, path *Path) (*reflect.Value, error) {
 
-    return IndirectPointer(s.rvv)
+    return IndirectPointerUnlessAlias(event, s.rvv)
 }
 
 func (s *MappingState) ObjEndInMapValueTyped(event *Event, path *Path) error {
+
+    // regular fields are set in place
+    if s.inlineKey == nil {
+        return nil
+    }
+
+    rvm, err := FieldByIndexAlloc(*s.rv, s.ti.inlineMap.index)
+    if err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+
+    if rvm.IsNil() {
+        rvm.Set(reflect.MakeMap(rvm.Type()))
+    }
+
+    rvm.SetMapIndex(*s.rvk, *s.rvv)
+
+    s.inlineKey = nil
+
     return nil
 }
 
-func (s *MappingState) ObjStartInMapKeyGeneric(path *Path) (*reflect.Value, error) {
+func (s *MappingState) ObjStartInMapKeyGeneric(event *Event, path *Path) (*reflect.Value, error) {
 
     dp := path.RootUserData().(DebugfProvider)
 
     // generic interface mapping
     dp.Debugf("%s: generic interface mapping key\n", path)
 
-    // fill in an interface{} as a key
-    var iv interface{}
-    rvt := reflect.ValueOf(&iv).Elem()
-    // rvt = reflect.ValueOf(&strkey).Elem()
+    // complex keys are decoded here as well, and made hashable at the end
+    rvt := reflect.New(s.rv.Type().Key()).Elem()
 
     if !rvt.IsValid() {
         return nil, errors.New(fmt.Sprintf("%v: Unable to retrieve ptr context", path))
@@ -577,7 +711,33 @@ func (s *MappingState) ObjStartInMapKeyGeneric(path *Path) (*reflect.Value, erro
     s.uf = nil
     s.rvv = nil
 
-    return IndirectPointer(s.rvk)
+    return IndirectPointerUnlessAlias(event, s.rvk)
+}
+
+func (s *MappingState) ObjStartInMapKeyConcrete(event *Event, path *Path) (*reflect.Value, error) {
+
+    dp := path.RootUserData().(DebugfProvider)
+
+    // map with a concrete key type (i.e. map[int]string)
+    dp.Debugf("%s: concrete mapping key\n", path)
+
+    // scalar keys are decoded to the key type by the schema scalar handlers
+    // (and complex keys to arrays or structs)
+    rvt := reflect.New(s.rv.Type().Key()).Elem()
+
+    if !rvt.IsValid() {
+        return nil, errors.New(fmt.Sprintf("%v: Unable to retrieve ptr context", path))
+    }
+    if !rvt.CanSet() {
+        return nil, errors.New(fmt.Sprintf("%v: cannot set the value", path))
+    }
+
+    s.rvk = &rvt
+    // no field info, nor value
+    s.uf = nil
+    s.rvv = nil
+
+    return IndirectPointerUnlessAlias(event, s.rvk)
 }
 
 func (s *MappingState) ObjEndInMapKeyGeneric(event *Event, path *Path) error {
@@ -598,53 +758,103 @@ func (s *MappingState) ObjStartInMapValueGeneric(event *Event, path *Path) (*ref
 
     s.rvv = &rvt
 
-    return IndirectPointer(s.rvv)
+    return IndirectPointerUnlessAlias(event, s.rvv)
 }
 
 func (s *MappingState) ObjEndInMapValueGeneric(event *Event, path *Path) error {
 
-    rvk := s.owk.StartRV()
-    rvv := s.owv.StartRV()
+    // note that these are the values before any pointer indirection
+    key, value := *s.rvk, *s.rvv
 
-    // find out if the type is hashable
-    hashable := IsHashable(*rvk)
+    // complex keys must be converted to something hashable
+    if !IsHashable(key) {
 
-    var key, value reflect.Value
+        // only interface keys can take the canonical form
+        if s.rv.Type().Key().Kind() != reflect.Interface {
+            return errors.New(fmt.Sprintf("%v: unhashable key of type %s on mapping", path, key.Type()))
+        }
 
-    if hashable {
-        // commit to the map
-        key, value = *rvk, *rvv
-    } else {
-        // make a string from the last component
-        str := path.LastComponent().String()
-        rvt := reflect.ValueOf(&str).Elem()
-        key, value = rvt, *rvv
+        ckey, err := CanonicalKey(key)
+        if err != nil {
+            return fmt.Errorf("%v: %w", path, err)
+        }
+        key = ckey
     }
 
-    chk := s.rv.MapIndex(key)
-
-    if chk.IsValid() {
-       return errors.New(fmt.Sprintf("%v: duplicate key %v on mapping", path, key))
-   }
+    // only the keys of this mapping are duplicates, not the ones already in the map
+    if _, exists := s.dupm[key.Interface()]; exists {
+        return errors.New(fmt.Sprintf("%v: duplicate key %v on mapping", path, key))
+    }
+    s.dupm[key.Interface()] = uvoid{}
 
     s.rv.SetMapIndex(key, value)
 
     return nil
 }
 
+// the keys and values of ordered mappings are generic
+func (s *MappingState) ObjStartInMapKeyOrdered(event *Event, path *Path) (*reflect.Value, error) {
+
+    rvt := reflect.New(genericIfaceType).Elem()
+
+    s.rvk = &rvt
+    s.uf = nil
+    s.rvv = nil
+
+    return IndirectPointerUnlessAlias(event, s.rvk)
+}
+
+func (s *MappingState) ObjStartInMapValueOrdered(event *Event, path *Path) (*reflect.Value, error) {
+
+    rvt := reflect.New(genericIfaceType).Elem()
+
+    s.rvv = &rvt
+
+    return IndirectPointerUnlessAlias(event, s.rvv)
+}
+
+func (s *MappingState) ObjEndInMapValueOrdered(event *Event, path *Path) error {
+
+    key, value := *s.rvk, *s.rvv
+
+    okey, err := orderedKey(key)
+    if err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+    if _, exists := s.dupo[okey]; exists {
+        return errors.New(fmt.Sprintf("%v: duplicate key %v on mapping", path, okey))
+    }
+    s.dupo[okey] = uvoid{}
+
+    item := MapItem{
+        Key: key.Interface(),
+        Value: value.Interface(),
+    }
+    s.rv.Set(reflect.Append(*s.rv, reflect.ValueOf(item)))
+
+    return nil
+}
+
 func (s *MappingState) ObjStartInMapKey(event *Event, path *Path) (*reflect.Value, error) {
 
-    if s.ri == nil {
-        return s.ObjStartInMapKeyTyped(path)
+    // structs are typed, maps (generic or not) are not
+    if s.ti != nil {
+        return s.ObjStartInMapKeyTyped(event, path)
+    } else if s.ordered {
+        return s.ObjStartInMapKeyOrdered(event, path)
+    } else if s.rv.Type().Key().Kind() != reflect.Interface {
+        return s.ObjStartInMapKeyConcrete(event, path)
     } else {
-        return s.ObjStartInMapKeyGeneric(path)
+        return s.ObjStartInMapKeyGeneric(event, path)
     }
 }
 
 func (s *MappingState) ObjStartInMapValue(event *Event, path *Path) (*reflect.Value, error) {
 
-    if s.ri == nil {
+    if s.ti != nil {
         return s.ObjStartInMapValueTyped(event, path)
+    } else if s.ordered {
+        return s.ObjStartInMapValueOrdered(event, path)
     } else {
         return s.ObjStartInMapValueGeneric(event, path)
     }
@@ -675,6 +885,18 @@ func (s *MappingState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, erro
 
     inKey := path.InMappingKey()
     if inKey {
+        s.inMerge = s.isMergeKey(event, path)
+    }
+
+    if s.inMerge {
+        // neither the merge key nor the value are stored
+        // the value is merged at the end of the mapping
+        rvt := reflect.New(genericIfaceType).Elem()
+        rv = &rvt
+        if !inKey {
+            s.rvv = rv
+        }
+    } else if inKey {
         rv, err = s.ObjStartInMapKey(event, path)
     } else {
         rv, err = s.ObjStartInMapValue(event, path)
@@ -705,16 +927,20 @@ func (s *MappingState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, erro
 }
 
 func (s *MappingState) ObjEndInMapKey(event *Event, path *Path) error {
-    if s.ri == nil {
+    if s.ti != nil {
         return s.ObjEndInMapKeyTyped(event, path)
+    } else if s.ordered {
+        return nil
     } else {
         return s.ObjEndInMapKeyGeneric(event, path)
     }
 }
 
 func (s *MappingState) ObjEndInMapValue(event *Event, path *Path) error {
-    if s.ri == nil {
+    if s.ti != nil {
         return s.ObjEndInMapValueTyped(event, path)
+    } else if s.ordered {
+        return s.ObjEndInMapValueOrdered(event, path)
     } else {
         return s.ObjEndInMapValueGeneric(event, path)
     }
@@ -729,7 +955,12 @@ func (s *MappingState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) erro
     var err error
 
     inKey := path.InMappingKey()
-    if inKey {
+    if s.inMerge {
+        if !inKey {
+            s.merges = append(s.merges, *s.rvv)
+            s.inMerge = false
+        }
+    } else if inKey {
         err = s.ObjEndInMapKey(event, path)
     } else {
         err = s.ObjEndInMapValue(event, path)
@@ -747,9 +978,13 @@ func (s *MappingState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) erro
 
     s.ow = nil
 
-    dp := path.RootUserData().(DebugfProvider)
     if anchor := ow.Anchor(); anchor != nil {
-        dp.Debugf("%s: Anchor %s\n", path, *anchor)
+        if r, hasR := path.RootUserData().(Resolver); hasR {
+            if err := r.RegisterAnchor(*anchor, path, ow, s, nil); err != nil {
+                return err
+            }
+        }
+        // schema does not provide resolver services
     }
 
     return nil
@@ -764,9 +999,21 @@ func (s *MappingState) CollectionStart(event *Event, path *Path) error {
 
     var ti *TypeInfo = nil
     var ri *reflect.Value = nil
+    ordered := false
 
     kind := rv.Kind()
     switch kind {
+    case reflect.Slice:
+
+        // only ordered mappings are slices
+        if rv.Type() != mapSliceType {
+            return errors.New(fmt.Sprintf("%v: illegal value type for mapping: %v", path, rv.Type()))
+        }
+
+        // start empty
+        rv.Set(reflect.Zero(rv.Type()))
+        ordered = true
+
     case reflect.Struct:
 
         // get the type services provider from the root object
@@ -781,10 +1028,30 @@ func (s *MappingState) CollectionStart(event *Event, path *Path) error {
             return errors.New(fmt.Sprintf("%s: could not lookup type %s\n", path, rv.Type()))
         }
 
+    case reflect.Map:
+
+        // a nil map must be created, else the contents are retained
+        if rv.IsNil() {
+            if !rv.CanSet() {
+                return errors.New(fmt.Sprintf("%v: cannot set the map value", path))
+            }
+            rv.Set(reflect.MakeMap(rv.Type()))
+        }
+
     case reflect.Interface:
         // save interface
         ri = rv
 
+        if GetObjectOptions(path.RootUserData()).OrderedMaps {
+
+            // an empty ordered mapping
+            sv := reflect.New(mapSliceType).Elem()
+            rv = &sv
+            ordered = true
+
+            break
+        }
+
         // create an empty [interface{}]interface{} value
         sv := reflect.New(genericMapType).Elem()
         sv.Set(reflect.MakeMap(genericMapType))
@@ -803,6 +1070,217 @@ func (s *MappingState) CollectionStart(event *Event, path *Path) error {
     s.ri = ri
     s.rv = rv
     s.ti = ti
+    s.ordered = ordered
+    if ordered {
+        s.dupo = make(map[interface{}]uvoid)
+    } else if ti == nil {
+        s.dupm = make(map[interface{}]uvoid)
+    }
+
+    return nil
+}
+
+// a plain << key (or tagged !!merge) when the schema has merge keys
+func (s *MappingState) isMergeKey(event *Event, path *Path) bool {
+
+    if event.Type() != Scalar || event.ScalarValue() != "<<" {
+        return false
+    }
+    if event.Token().ScalarStyle() != Plain {
+        return false
+    }
+    if tag := event.Tag(); tag != nil && tag.Text() != DefaultLongTagPrefix + "merge" {
+        return false
+    }
+
+    return MergeKeysEnabled(s.SchemaImplementer(), GetObjectOptions(path.RootUserData()))
+}
+
+// the mapping to merge from a merge key value
+func mergeSource(v reflect.Value, c *Converter) (reflect.Value, error) {
+
+    for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            return reflect.Value{}, errors.New("merge of null value")
+        }
+        v = v.Elem()
+    }
+
+    if v.Type() == mapSliceType {
+        return v, nil
+    }
+
+    switch v.Kind() {
+    case reflect.Map:
+        return v, nil
+
+    case reflect.Struct:
+        // an anchored struct merges its fields, in order
+        if keys, values, isMapping := c.mappingPairs(v); isMapping {
+            ms := make(MapSlice, 0, len(keys))
+            for i, key := range keys {
+                ms = append(ms, MapItem{Key: key.Interface(), Value: values[i].Interface()})
+            }
+            return reflect.ValueOf(ms), nil
+        }
+    }
+
+    return reflect.Value{}, errors.New(fmt.Sprintf("cannot merge a %s (must be a mapping or a sequence of mappings)", v.Type()))
+}
+
+// merge the values of the merge keys; the explicit keys
+// (and the earlier merged ones) take precedence
+func (s *MappingState) mergeKeys(path *Path) error {
+
+    c := NewPathConverter(path, s.SchemaImplementer())
+
+    // collect the mappings to merge in order
+    var sources []reflect.Value
+    for _, m := range s.merges {
+
+        v := m
+        for v.Kind() == reflect.Interface && !v.IsNil() {
+            v = v.Elem()
+        }
+
+        if v.Kind() == reflect.Slice && v.Type() != mapSliceType {
+            for i := 0; i < v.Len(); i++ {
+                src, err := mergeSource(v.Index(i), c)
+                if err != nil {
+                    return fmt.Errorf("%v: %w", path, err)
+                }
+                sources = append(sources, src)
+            }
+            continue
+        }
+
+        src, err := mergeSource(v, c)
+        if err != nil {
+            return fmt.Errorf("%v: %w", path, err)
+        }
+        sources = append(sources, src)
+    }
+
+    for _, src := range sources {
+
+        // ordered mappings merge in order
+        if src.Type() == mapSliceType {
+            for _, item := range src.Interface().(MapSlice) {
+                k := reflect.ValueOf(&item.Key).Elem()
+                v := reflect.ValueOf(&item.Value).Elem()
+                if err := s.mergeKey(path, k, v, c); err != nil {
+                    return err
+                }
+            }
+            continue
+        }
+
+        // the rest in the natural order, for the order of the result
+        for _, k := range naturalMapKeys(src) {
+            if err := s.mergeKey(path, k, src.MapIndex(k), c); err != nil {
+                return err
+            }
+        }
+    }
+
+    s.merges = nil
+
+    return nil
+}
+
+func (s *MappingState) mergeKey(path *Path, k, v reflect.Value, c *Converter) error {
+
+    // ordered mappings append the keys not present
+    if s.ordered {
+        okey, err := orderedKey(k)
+        if err != nil {
+            return fmt.Errorf("%v: %w", path, err)
+        }
+        if _, exists := s.dupo[okey]; exists {
+            return nil
+        }
+        s.dupo[okey] = uvoid{}
+
+        item := MapItem{
+            Key: k.Interface(),
+            Value: v.Interface(),
+        }
+        s.rv.Set(reflect.Append(*s.rv, reflect.ValueOf(item)))
+        return nil
+    }
+
+    // maps (generic or not)
+    if s.ti == nil {
+        key := reflect.New(s.rv.Type().Key()).Elem()
+        if err := c.Convert(key, k); err != nil {
+            return fmt.Errorf("%v: merge key %v: %w", path, k, err)
+        }
+        if _, exists := s.dupm[key.Interface()]; exists {
+            return nil
+        }
+        s.dupm[key.Interface()] = uvoid{}
+
+        value := reflect.New(s.rv.Type().Elem()).Elem()
+        if err := c.Convert(value, v); err != nil {
+            return fmt.Errorf("%v: merge key %v: %w", path, k, err)
+        }
+        s.rv.SetMapIndex(key, value)
+        return nil
+    }
+
+    // structs are addressed by name only
+    for k.Kind() == reflect.Interface && !k.IsNil() {
+        k = k.Elem()
+    }
+    if k.Kind() != reflect.String {
+        return errors.New(fmt.Sprintf("%v: merge key %v is not a string for %s", path, k, s.rv.Type()))
+    }
+    strkey := k.String()
+
+    rvv, uf, err := s.ti.FieldByName(strkey, s.rv)
+    if err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+
+    if rvv != nil {
+        if _, exists := s.dupf[uf]; exists {
+            return nil
+        }
+        s.dupf[uf]=uvoid{}
+
+        if err := c.Convert(*rvv, v); err != nil {
+            return fmt.Errorf("%v: merge key %s: %w", path, strkey, err)
+        }
+        return nil
+    }
+
+    if s.ti.inlineMap == nil {
+        return errors.New(fmt.Sprintf("%v: illegal merge key field %s", path, strkey))
+    }
+
+    if _, exists := s.dupk[strkey]; exists {
+        return nil
+    }
+    s.dupk[strkey]=uvoid{}
+
+    rvm, err := FieldByIndexAlloc(*s.rv, s.ti.inlineMap.index)
+    if err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+    if rvm.IsNil() {
+        rvm.Set(reflect.MakeMap(rvm.Type()))
+    }
+
+    value := reflect.New(rvm.Type().Elem()).Elem()
+    if err := c.Convert(value, v); err != nil {
+        return fmt.Errorf("%v: merge key %s: %w", path, strkey, err)
+    }
+
+    // the merged keys are strings
+    if rvm.Type().Key().Kind() != reflect.String {
+        return errors.New(fmt.Sprintf("%v: merge key %s cannot be stored to a key of %s", path, strkey, rvm.Type()))
+    }
+    rvm.SetMapIndex(reflect.ValueOf(strkey).Convert(rvm.Type().Key()), value)
 
     return nil
 }
@@ -813,6 +1291,19 @@ func (s *MappingState) CollectionEnd(event *Event, path *Path) error {
 
     dp.Debugf("ReflectionMappingEnd %s\n", path)
 
+    // the merge keys are applied last, so the explicit keys win
+    if len(s.merges) > 0 {
+        if err := s.mergeKeys(path); err != nil {
+            return err
+        }
+    }
+
+    // ordered mappings are set as they are
+    if s.ri != nil && s.ordered {
+        s.ri.Set(*s.rv)
+        return nil
+    }
+
     // if we're on an interface, set it (should be settable)
     if s.ri != nil {
 
@@ -8,6 +8,7 @@ import (
     "unsafe"
     "strings"
     "strconv"
+    "time"
 )
 
 // please note that libfyaml produces full tag forms by default
@@ -82,25 +83,29 @@ func (s *RefState) SetScalar(event *Event, path *Path) error {
 
     dp.Debugf("value %v\n", *ow.StartRV())
 
-    rv := s.startRv
-    targetRv := ow.StartRV()
-
-    kind := rv.Kind()
-    targetKind := targetRv.Kind()
-
-    // kinds match?
-    if kind == targetKind {
-        if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store alias %s", path, s.ref))
-        }
-        rv.Set(*targetRv)
-    } else {
-        return errors.New(fmt.Sprintf("%v: cannot store alias %s, mismatched types %s %s", path, s.ref, kind, targetKind))
+    // the alias target is the value itself (not pointer indirected)
+    c := NewPathConverter(path, s.ys.si)
+    if err := c.Convert(*s.startRv, *ow.StartRV()); err != nil {
+        return fmt.Errorf("%v: cannot store alias %s: %w", path, s.ref, err)
     }
 
     return nil
 }
 
+func isNumberOrBoolKind(kind reflect.Kind) bool {
+    return kind == reflect.Bool || isNumberKind(kind)
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+    switch kind {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+         reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+         reflect.Float32, reflect.Float64:
+        return true
+    }
+    return false
+}
+
 type RefTag struct {
     si SchemaImplementer
 }
@@ -148,6 +153,13 @@ type YAMLSchema struct {
     seqT SeqTag
     mapT MapTag
 
+    // the 1.1 types (not in failsafe and json)
+    timestampT TimestampTag
+    binaryT BinaryTag
+    setT SetTag
+    omapT OmapTag
+    pairsT PairsTag
+
     // the invisible internal reference tag
     refT RefTag
 }
@@ -166,8 +178,12 @@ func NewYAMLSchema(st YAMLSchemaType, si SchemaImplementer) *YAMLSchema {
     case FailsafeSchema:
         tags = []TagHandler{ &ys.strT, &ys.seqT, &ys.mapT }
 
-    default:
+    case JSONSchema:
         tags = []TagHandler{ &ys.strT, &ys.boolT, &ys.nullT, &ys.intT, &ys.floatT, &ys.seqT, &ys.mapT }
+
+    default:
+        tags = []TagHandler{ &ys.strT, &ys.boolT, &ys.nullT, &ys.intT, &ys.floatT, &ys.seqT, &ys.mapT,
+                             &ys.timestampT, &ys.binaryT, &ys.setT, &ys.omapT, &ys.pairsT }
     }
 
     // initialize the supported tags
@@ -181,6 +197,11 @@ func NewYAMLSchema(st YAMLSchemaType, si SchemaImplementer) *YAMLSchema {
     t.SetSchemaImplementer(si)
     // note that there's no textual representation
 
+    // typed values are decoded even when the tag is not supported
+    for _, t := range []TagHandler{ &ys.timestampT, &ys.binaryT, &ys.setT, &ys.omapT, &ys.pairsT } {
+        t.SetSchemaImplementer(si)
+    }
+
     return ys
 }
 
@@ -262,17 +283,19 @@ func (ys *YAMLSchema) ImplicitResolve(vp *string) TagHandler {
             return &ys.boolT
         }
 
-        // float infinities
-        if *vp == ".nan" || *vp == ".NaN" || *vp == ".NAN" {
-            return &ys.floatT
+        // timestamp
+        if isTimestamp(*vp) {
+            return &ys.timestampT
         }
 
-        // float infinites
-        if *vp == ".inf" || *vp == ".Inf" || *vp == ".INF" ||
-           *vp == "-.inf" || *vp == "-.Inf" || *vp == "-.INF" ||
-           *vp == "+.inf" || *vp == "+.Inf" || *vp == "+.INF" {
+        // the 1.1 numbers; everything else is a string
+        if yaml11IntRe.MatchString(*vp) {
+            return &ys.intT
+        }
+        if yaml11FloatRe.MatchString(*vp) {
             return &ys.floatT
         }
+        return &ys.strT
     }
 
     if vp == nil {
@@ -322,9 +345,9 @@ func (ys *YAMLSchema) ImplicitResolve(vp *string) TagHandler {
             }
             i++
 
-            for i < l && (v[i] >= '0' && v[i] <= '9') ||
-                         (v[i] >= 'a' && v[i] <= 'f') ||
-                         (v[i] >= 'A' && v[i] <= 'F') {
+            for i < l && ((v[i] >= '0' && v[i] <= '9') ||
+                          (v[i] >= 'a' && v[i] <= 'f') ||
+                          (v[i] >= 'A' && v[i] <= 'F')) {
                 i++
             }
         }
@@ -384,7 +407,7 @@ func (ys *YAMLSchema) ImplicitResolve(vp *string) TagHandler {
     }
 
     // no? try scientific part
-    if v[i] != 'e' && v[i] <= 'E' {
+    if v[i] != 'e' && v[i] != 'E' {
         return &ys.strT
     }
     i++
@@ -428,6 +451,11 @@ func (ys *YAMLSchema) NewSchemaObject(event *Event, path *Path, startRv *reflect
         }, nil
     }
 
+    // custom unmarshalers take precedence
+    if ow, err := NewCustomState(event, path, startRv, ys.si); ow != nil || err != nil {
+        return ow, err
+    }
+
     // find the tag handler (note we use the schema callback)
     th, _, err := ys.si.FindTagHandler(event, path, startRv)
     if err != nil {
@@ -464,16 +492,51 @@ func (ys *YAMLSchema) FindTagHandler(event *Event, path *Path, rv *reflect.Value
     // select something implicitly (if it's sequence or a mapping)
     switch etype {
     case SequenceStart:
+        // the pairs types are typed by the target
+        if rv.IsValid() && ys.st != FailsafeSchema {
+            switch rv.Type() {
+            case omapType:
+                return &ys.omapT, false, nil
+            case pairsType:
+                return &ys.pairsT, false, nil
+            }
+        }
+
         // failsafe safe
         return &ys.seqT, false, nil
 
     case MappingStart:
+        // and so are the sets
+        if rv.IsValid() && ys.st != FailsafeSchema && isSetType(rv.Type()) {
+            return &ys.setT, false, nil
+        }
+
         // failsafe safe
         return &ys.mapT, false, nil
 
     case Scalar:
+        // time values are typed by the target, whatever the style
+        if rv.IsValid() && ys.st != FailsafeSchema {
+            switch rv.Type() {
+            case timeType:
+                return &ys.timestampT, false, nil
+            case durationType:
+                return &ys.intT, false, nil
+            }
+        }
+
+        // byte slices and arrays are base64 only when tagged !!binary,
+        // except in JSON which has no tags (and is encoded so)
+        if rv.IsValid() && isBinaryType(rv.Type()) {
+            if ys.st == JSONSchema {
+                return &ys.binaryT, false, nil
+            }
+            return &ys.strT, false, nil
+        }
+
         // a scalar; if it's anything other than plain style it's a string
-        if event.Token().ScalarStyle() != Plain {
+        // (but not for typed keys in JSON, where keys are always quoted)
+        if event.Token().ScalarStyle() != Plain && !(ys.st == JSONSchema && path.InMappingKey() && isNumberOrBoolKind(rv.Kind())) {
             // failsafe safe
             return &ys.strT, false, nil
         }
@@ -503,8 +566,14 @@ func (ys *YAMLSchema) FindTagHandler(event *Event, path *Path, rv *reflect.Value
             }
 
         case reflect.Interface:
+            // timestamps might be enabled for the core schemas
+            vp := event.ScalarValuePtr()
+            if vp != nil && TimestampsEnabled(ys.si, GetObjectOptions(path.RootUserData())) && isTimestamp(*vp) {
+                return &ys.timestampT, false, nil
+            }
+
             // everything failed, we have to figure it out from the contents
-            if th := ys.ImplicitResolve(event.ScalarValuePtr()); th != nil {
+            if th := ys.ImplicitResolve(vp); th != nil {
                 return th, false, nil
             }
         }
@@ -560,6 +629,13 @@ func (ys *YAMLSchema) ResolveScalar(tag, value *string, kind reflect.Kind) (TagH
             return th, th.Specify(kind)
         }
 
+    case reflect.Struct:
+        // the only scalar structs are time values
+        if ys.st != FailsafeSchema {
+            th := &ys.timestampT
+            return th, th.Specify(kind)
+        }
+
     case reflect.Interface:
         // everything failed, we have to figure it out from the contents
         if th := ys.ImplicitResolve(value); th != nil {
@@ -581,8 +657,57 @@ type YAMLSchemaProvider interface {
     YAMLSchema() *YAMLSchema
 }
 
+// merge keys (<<) are part of the 1.1 schema, opt-in for core and 1.3
+func MergeKeysEnabled(si SchemaImplementer, opts *Options) bool {
+
+    st := CoreSchema
+    if ysp, hasYsp := si.(YAMLSchemaProvider); hasYsp {
+        st = ysp.YAMLSchema().YAMLSchemaType()
+    }
+
+    switch st {
+    case YAML11Schema:
+        return true
+    case CoreSchema, YAML13Schema:
+        return opts.MergeKeys
+    }
+    return false
+}
+
+// timestamps are part of the 1.1 schema, opt-in for core and 1.3
+func TimestampsEnabled(si SchemaImplementer, opts *Options) bool {
+
+    st := CoreSchema
+    if ysp, hasYsp := si.(YAMLSchemaProvider); hasYsp {
+        st = ysp.YAMLSchema().YAMLSchemaType()
+    }
+
+    switch st {
+    case YAML11Schema:
+        return true
+    case CoreSchema, YAML13Schema:
+        return opts.Timestamps
+    }
+    return false
+}
+
 ////////////////////////////////////////////////////////
 
+// the scalar states store the text of the event through their tag handler
+func setScalarText(sw ScalarWrapper, event *Event, path *Path) error {
+
+    ts, hasTs := sw.TagHandler().(ScalarTextSetter)
+    if !hasTs {
+        return errors.New(fmt.Sprintf("%v: cannot store scalar text for %s", path, sw.TagHandler().Tag()))
+    }
+
+    if err := ts.SetScalarText(*sw.StartRV(), event.ScalarValuePtr()); err != nil {
+        return fmt.Errorf("%v: %w", path, err)
+    }
+
+    return nil
+}
+
 // !!str
 type StrState struct {
     sw ScalarWrapper
@@ -607,10 +732,29 @@ func (s *StrState) SchemaImplementer() SchemaImplementer {
 
 // the ScalarWrapper interface
 func (s *StrState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type StrTag struct {
+    si SchemaImplementer
+}
+
+func (t *StrTag) Tag() string {
+    return DefaultLongTagPrefix + "str"
+}
+
+func (t *StrTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *StrTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
 
-    rv := s.StartRV()
+// the ScalarTextSetter interface
+func (t *StrTag) SetScalarText(rv reflect.Value, vp *string) error {
 
-    value := event.ScalarValue()
+    value := stringOrEmpty(vp)
 
     switch kind := rv.Kind(); kind {
 
@@ -619,40 +763,36 @@ func (s *StrState) SetScalar(event *Event, path *Path) error {
 
     case reflect.Interface:
         if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store string", path))
+            return errors.New("cannot address to store string")
         }
         rv.Set(reflect.ValueOf(value))
 
+    case reflect.Slice, reflect.Array:
+        // the raw text to bytes
+        if !isBinaryType(rv.Type()) {
+            return errors.New(fmt.Sprintf("cannot store string to %s", rv.Type()))
+        }
+        return setBytes(rv, []byte(value))
+
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
     return nil
 }
 
-type StrTag struct {
-    si SchemaImplementer
-}
-
-func (t *StrTag) Tag() string {
-    return DefaultLongTagPrefix + "str"
-}
-
-func (t *StrTag) SetSchemaImplementer(si SchemaImplementer) {
-    t.si = si
-}
-
-func (t *StrTag) SchemaImplementer() SchemaImplementer {
-    return t.si
-}
-
 func (t *StrTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
 
     // we need to descriminate (we don't allow arbitrary types for storage)
     switch kind := startRv.Kind(); kind {
     case reflect.String, reflect.Interface:
         // OK
+    case reflect.Slice, reflect.Array:
+        // the text of byte slices and arrays
+        if !isBinaryType(startRv.Type()) {
+            return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
+        }
     default:
         return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
     }
@@ -698,11 +838,30 @@ func (s *BoolState) SchemaImplementer() SchemaImplementer {
 
 // the ScalarWrapper interface
 func (s *BoolState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type BoolTag struct {
+    si SchemaImplementer
+}
 
-    rv := s.sw.StartRV()
+func (t *BoolTag) Tag() string {
+    return DefaultLongTagPrefix + "bool"
+}
+
+func (t *BoolTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *BoolTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
+
+// the ScalarTextSetter interface
+func (t *BoolTag) SetScalarText(rv reflect.Value, vp *string) error {
 
     // get the scalar value
-    str := event.ScalarValue()
+    str := stringOrEmpty(vp)
 
     isValid, value := false, false
 
@@ -710,7 +869,7 @@ func (s *BoolState) SetScalar(event *Event, path *Path) error {
     st := CoreSchema
 
     // get the schema type
-    if ysp, hasYsp := s.SchemaImplementer().(YAMLSchemaProvider); hasYsp {
+    if ysp, hasYsp := t.si.(YAMLSchemaProvider); hasYsp {
         st = ysp.YAMLSchema().YAMLSchemaType()
     }
 
@@ -740,7 +899,7 @@ func (s *BoolState) SetScalar(event *Event, path *Path) error {
     }
 
     if !isValid {
-        return errors.New(fmt.Sprintf("%v: invalid scalar %s to store to bool", path, str))
+        return errors.New(fmt.Sprintf("invalid scalar %s to store to bool", str))
     }
 
     switch kind := rv.Kind(); kind {
@@ -750,34 +909,18 @@ func (s *BoolState) SetScalar(event *Event, path *Path) error {
 
     case reflect.Interface:
         if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store string", path))
+            return errors.New("cannot address to store string")
         }
         rv.Set(reflect.ValueOf(value))
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
     return nil
 }
 
-type BoolTag struct {
-    si SchemaImplementer
-}
-
-func (t *BoolTag) Tag() string {
-    return DefaultLongTagPrefix + "bool"
-}
-
-func (t *BoolTag) SetSchemaImplementer(si SchemaImplementer) {
-    t.si = si
-}
-
-func (t *BoolTag) SchemaImplementer() SchemaImplementer {
-    return t.si
-}
-
 func (t *BoolTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
 
     // we need to descriminate (we don't allow arbitrary types for storage)
@@ -829,10 +972,27 @@ func (s *NullState) SchemaImplementer() SchemaImplementer {
 
 // the ScalarWrapper interface
 func (s *NullState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type NullTag struct {
+    si SchemaImplementer
+}
+
+func (t *NullTag) Tag() string {
+    return DefaultLongTagPrefix + "null"
+}
+
+func (t *NullTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
 
-    rv := s.sw.StartRV()
+func (t *NullTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
 
-    strp := event.ScalarValuePtr()
+// the ScalarTextSetter interface
+func (t *NullTag) SetScalarText(rv reflect.Value, vp *string) error {
 
     isValid := false
 
@@ -840,7 +1000,7 @@ func (s *NullState) SetScalar(event *Event, path *Path) error {
     st := CoreSchema
 
     // get the schema type
-    if ysp, hasYsp := s.SchemaImplementer().(YAMLSchemaProvider); hasYsp {
+    if ysp, hasYsp := t.si.(YAMLSchemaProvider); hasYsp {
         st = ysp.YAMLSchema().YAMLSchemaType()
     }
 
@@ -851,16 +1011,16 @@ func (s *NullState) SetScalar(event *Event, path *Path) error {
 
     case JSONSchema:
         // JSON schema is simple
-        isValid = strp != nil && *strp == "null"
+        isValid = vp != nil && *vp == "null"
 
         // the core schema is the default
     case CoreSchema, YAML13Schema, YAML11Schema:
-        isValid = strp == nil || *strp == "null" || *strp == "Null" || *strp == "NULL" || *strp == "~"
+        isValid = vp == nil || *vp == "null" || *vp == "Null" || *vp == "NULL" || *vp == "~"
 
     }
 
     if !isValid {
-        return errors.New(fmt.Sprintf("%v: invalid scalar %s for null", path, *strp))
+        return errors.New(fmt.Sprintf("invalid scalar %s for null", stringOrEmpty(vp)))
     }
 
     switch kind := rv.Kind(); kind {
@@ -870,34 +1030,18 @@ func (s *NullState) SetScalar(event *Event, path *Path) error {
 
     case reflect.Interface:
         if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store null", path))
+            return errors.New("cannot address to store null")
         }
         rv.Set(reflect.Zero(rv.Type()))
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for null", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for null", kind))
     }
 
     return nil
 }
 
-type NullTag struct {
-    si SchemaImplementer
-}
-
-func (t *NullTag) Tag() string {
-    return DefaultLongTagPrefix + "null"
-}
-
-func (t *NullTag) SetSchemaImplementer(si SchemaImplementer) {
-    t.si = si
-}
-
-func (t *NullTag) SchemaImplementer() SchemaImplementer {
-    return t.si
-}
-
 func (t *NullTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
 
     // we need to descriminate (we don't allow arbitrary types for storage)
@@ -949,14 +1093,41 @@ func (s *IntState) SchemaImplementer() SchemaImplementer {
 
 // the ScalarWrapper interface
 func (s *IntState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type IntTag struct {
+    si SchemaImplementer
+}
+
+func (t *IntTag) Tag() string {
+    return DefaultLongTagPrefix + "int"
+}
+
+func (t *IntTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *IntTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
 
-    rv := s.sw.StartRV()
+// the ScalarTextSetter interface
+func (t *IntTag) SetScalarText(rv reflect.Value, vp *string) error {
 
     prec := 0
     signed := false
 
     // get the scalar value
-    str := event.ScalarValue()
+    str := stringOrEmpty(vp)
+
+    // durations are go duration strings (or plain nanoseconds)
+    if rv.Type() == durationType {
+        if d, err := time.ParseDuration(str); err == nil {
+            rv.SetInt(int64(d))
+            return nil
+        }
+    }
 
     base := 10
 
@@ -964,7 +1135,7 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
     st := CoreSchema
 
     // get the schema type
-    if ysp, hasYsp := s.SchemaImplementer().(YAMLSchemaProvider); hasYsp {
+    if ysp, hasYsp := t.si.(YAMLSchemaProvider); hasYsp {
         st = ysp.YAMLSchema().YAMLSchemaType()
     }
 
@@ -973,7 +1144,16 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
     case FailsafeSchema:
         // failsafe does not have an int, how did we get here?
 
-    case YAML11Schema, CoreSchema, YAML13Schema:
+    case YAML11Schema:
+
+        // all the 1.1 forms are converted to decimal
+        dstr, err := yaml11IntDecimal(str)
+        if err != nil {
+            return fmt.Errorf("cannot convert to integer: %w", err)
+        }
+        str = dstr
+
+    case CoreSchema, YAML13Schema:
 
         if strings.HasPrefix(str, "0o") {
             base = 8
@@ -1034,11 +1214,11 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
         signed = false
 
     case reflect.Int64:
-        prec = 32
+        prec = 64
         signed = true
 
     case reflect.Uint64:
-        prec = 32
+        prec = 64
         signed = false
 
     case reflect.Float32:
@@ -1051,7 +1231,7 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
     // base 8 and 10 are always unsigned
@@ -1071,7 +1251,7 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
         }
 
         if err != nil {
-            return errors.New(fmt.Sprintf("%v: cannot convert to signed integer with %d bits of precision: %s", path, prec, err.Error()))
+            return fmt.Errorf("cannot convert to signed integer with %d bits of precision: %w", prec, err)
         }
 
         switch kind {
@@ -1084,14 +1264,14 @@ func (s *IntState) SetScalar(event *Event, path *Path) error {
 
         case reflect.Interface:
             if !rv.CanAddr() {
-                return errors.New(fmt.Sprintf("%v: cannot address to store int", path))
+                return errors.New("cannot address to store int")
             }
             ivalue := int(value)
             rv.Set(reflect.ValueOf(ivalue))
 
         default:
             // should never get here, but, check anyway
-            return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+            return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
         }
         return nil
     }
@@ -1105,7 +1285,7 @@ do_unsigned:
 
     value, err := strconv.ParseUint(str, base, prec)
     if err != nil {
-        return errors.New(fmt.Sprintf("%v: cannot convert to unsigned integer with %d bits of precision: %s", path, prec, err.Error()))
+        return fmt.Errorf("cannot convert to unsigned integer with %d bits of precision: %w", prec, err)
     }
 
     switch kind {
@@ -1121,35 +1301,19 @@ do_unsigned:
 
     case reflect.Interface:
         if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store int", path))
+            return errors.New("cannot address to store int")
         }
         uivalue := uint(value)
         rv.Set(reflect.ValueOf(uivalue))
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
     return nil
 }
 
-type IntTag struct {
-    si SchemaImplementer
-}
-
-func (t *IntTag) Tag() string {
-    return DefaultLongTagPrefix + "int"
-}
-
-func (t *IntTag) SetSchemaImplementer(si SchemaImplementer) {
-    t.si = si
-}
-
-func (t *IntTag) SchemaImplementer() SchemaImplementer {
-    return t.si
-}
-
 func (t *IntTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
 
     // we need to descriminate (we don't allow arbitrary types for storage)
@@ -1216,13 +1380,32 @@ func (s *FloatState) SchemaImplementer() SchemaImplementer {
 
 // the ScalarWrapper interface
 func (s *FloatState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type FloatTag struct {
+    si SchemaImplementer
+}
+
+func (t *FloatTag) Tag() string {
+    return DefaultLongTagPrefix + "float"
+}
+
+func (t *FloatTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *FloatTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
 
-    rv := s.StartRV()
+// the ScalarTextSetter interface
+func (t *FloatTag) SetScalarText(rv reflect.Value, vp *string) error {
 
     prec := 0
 
     // get the scalar value
-    str := event.ScalarValue()
+    str := stringOrEmpty(vp)
 
     // two time check
     kind := rv.Kind()
@@ -1236,57 +1419,252 @@ func (s *FloatState) SetScalar(event *Event, path *Path) error {
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
-    value, err := strconv.ParseFloat(str, prec)
-    if err != nil {
-        return errors.New(fmt.Sprintf("%v: cannot convert to float with %d bits of precision: %s", path, prec, err.Error()))
-    }
+    // default is the core schema
+    st := CoreSchema
 
-    switch kind {
+    // get the schema type
+    if ysp, hasYsp := t.si.(YAMLSchemaProvider); hasYsp {
+        st = ysp.YAMLSchema().YAMLSchemaType()
+    }
+
+    // the 1.1 forms (integers too) are converted first
+    if st == YAML11Schema {
+        if fstr, err := yaml11FloatText(str); err == nil {
+            str = fstr
+        } else if istr, err := yaml11IntDecimal(str); err == nil {
+            str = istr
+        }
+    }
+
+    // infinities and not a number (JSON has neither)
+    value, isSpecial := 0.0, false
+    if st != JSONSchema {
+        value, isSpecial = floatSpecial(str)
+    }
+
+    if !isSpecial {
+        var err error
+        value, err = strconv.ParseFloat(str, prec)
+        if err != nil {
+            return fmt.Errorf("cannot convert to float with %d bits of precision: %w", prec, err)
+        }
+    }
+
+    switch kind {
 
     case reflect.Float32, reflect.Float64:
         rv.SetFloat(value)
 
     case reflect.Interface:
         if !rv.CanAddr() {
-            return errors.New(fmt.Sprintf("%v: cannot address to store int", path))
+            return errors.New("cannot address to store int")
         }
         rv.Set(reflect.ValueOf(value))
 
     default:
         // should never get here, but, check anyway
-        return errors.New(fmt.Sprintf("%v: cannot handle kind %v for scalar", path, kind))
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
     }
 
     return nil
 }
 
-type FloatTag struct {
+func (t *FloatTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+
+    // we need to descriminate (we don't allow arbitrary types for storage)
+    switch kind := startRv.Kind(); kind {
+    case reflect.Float32, reflect.Float64,  // we allow floats (but with limited precision)
+         reflect.Interface:
+        // OK
+    default:
+        return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
+    }
+
+    sw, err := NewScalarStateDefault(event, path, startRv, t)
+    if err != nil {
+        return nil, err
+    }
+
+    return &FloatState {
+        sw: sw,
+    }, nil
+}
+
+func (t *FloatTag) Specify(kind reflect.Kind) reflect.Kind {
+
+    switch kind {
+    case reflect.Interface:
+        return reflect.Float64
+
+    case reflect.Float32, reflect.Float64:
+         return kind
+    }
+    return reflect.Invalid
+}
+
+// !!timestamp
+type TimestampState struct {
+    sw ScalarWrapper
+}
+
+// the ObjectWrapper interface
+func (s *TimestampState) StartRV() *reflect.Value {
+    return s.sw.StartRV()
+}
+
+func (s *TimestampState) Anchor() *string {
+    return s.sw.Anchor()
+}
+
+func (s *TimestampState) TagHandler() TagHandler {
+    return s.sw.TagHandler()
+}
+
+func (s *TimestampState) SchemaImplementer() SchemaImplementer {
+    return s.sw.SchemaImplementer()
+}
+
+// the ScalarWrapper interface
+func (s *TimestampState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type TimestampTag struct {
     si SchemaImplementer
 }
 
-func (t *FloatTag) Tag() string {
-    return DefaultLongTagPrefix + "float"
+func (t *TimestampTag) Tag() string {
+    return DefaultLongTagPrefix + "timestamp"
 }
 
-func (t *FloatTag) SetSchemaImplementer(si SchemaImplementer) {
+func (t *TimestampTag) SetSchemaImplementer(si SchemaImplementer) {
     t.si = si
 }
 
-func (t *FloatTag) SchemaImplementer() SchemaImplementer {
+func (t *TimestampTag) SchemaImplementer() SchemaImplementer {
     return t.si
 }
 
-func (t *FloatTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+// the ScalarTextSetter interface
+func (t *TimestampTag) SetScalarText(rv reflect.Value, vp *string) error {
+
+    value, err := parseTimestamp(stringOrEmpty(vp))
+    if err != nil {
+        return err
+    }
+
+    if !rv.CanSet() {
+        return errors.New("cannot address to store timestamp")
+    }
+    rv.Set(reflect.ValueOf(value))
+
+    return nil
+}
+
+func (t *TimestampTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+
+    // only time values and generics
+    if kind := startRv.Kind(); kind != reflect.Interface && (kind != reflect.Struct || startRv.Type() != timeType) {
+        return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
+    }
+
+    sw, err := NewScalarStateDefault(event, path, startRv, t)
+    if err != nil {
+        return nil, err
+    }
+
+    return &TimestampState {
+        sw: sw,
+    }, nil
+}
+
+func (t *TimestampTag) Specify(kind reflect.Kind) reflect.Kind {
+
+    switch kind {
+    case reflect.Interface, reflect.Struct:
+        return reflect.Struct
+    }
+    return reflect.Invalid
+}
+
+// !!binary
+type BinaryState struct {
+    sw ScalarWrapper
+}
+
+// the ObjectWrapper interface
+func (s *BinaryState) StartRV() *reflect.Value {
+    return s.sw.StartRV()
+}
+
+func (s *BinaryState) Anchor() *string {
+    return s.sw.Anchor()
+}
+
+func (s *BinaryState) TagHandler() TagHandler {
+    return s.sw.TagHandler()
+}
+
+func (s *BinaryState) SchemaImplementer() SchemaImplementer {
+    return s.sw.SchemaImplementer()
+}
+
+// the ScalarWrapper interface
+func (s *BinaryState) SetScalar(event *Event, path *Path) error {
+    return setScalarText(s.sw, event, path)
+}
+
+type BinaryTag struct {
+    si SchemaImplementer
+}
+
+func (t *BinaryTag) Tag() string {
+    return DefaultLongTagPrefix + "binary"
+}
+
+func (t *BinaryTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *BinaryTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
+
+// the ScalarTextSetter interface
+func (t *BinaryTag) SetScalarText(rv reflect.Value, vp *string) error {
+
+    data, err := decodeBinary(stringOrEmpty(vp))
+    if err != nil {
+        return fmt.Errorf("cannot decode binary: %w", err)
+    }
+
+    if !rv.CanSet() {
+        return errors.New("cannot address to store binary")
+    }
+
+    switch kind := rv.Kind(); kind {
+
+    case reflect.Interface:
+        rv.Set(reflect.ValueOf(data))
+
+    case reflect.Slice, reflect.Array:
+        return setBytes(rv, data)
 
-    // we need to descriminate (we don't allow arbitrary types for storage)
-    switch kind := startRv.Kind(); kind {
-    case reflect.Float32, reflect.Float64,  // we allow floats (but with limited precision)
-         reflect.Interface:
-        // OK
     default:
+        // should never get here, but, check anyway
+        return errors.New(fmt.Sprintf("cannot handle kind %v for scalar", kind))
+    }
+
+    return nil
+}
+
+func (t *BinaryTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+
+    // only byte slices, arrays and generics
+    if kind := startRv.Kind(); kind != reflect.Interface && (!startRv.IsValid() || !isBinaryType(startRv.Type())) {
         return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
     }
 
@@ -1295,18 +1673,18 @@ func (t *FloatTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Va
         return nil, err
     }
 
-    return &FloatState {
+    return &BinaryState {
         sw: sw,
     }, nil
 }
 
-func (t *FloatTag) Specify(kind reflect.Kind) reflect.Kind {
+func (t *BinaryTag) Specify(kind reflect.Kind) reflect.Kind {
 
     switch kind {
     case reflect.Interface:
-        return reflect.Float64
+        return reflect.Slice
 
-    case reflect.Float32, reflect.Float64:
+    case reflect.Slice, reflect.Array:
          return kind
     }
     return reflect.Invalid
@@ -1378,6 +1756,343 @@ func (t *MapTag) Specify(kind reflect.Kind) reflect.Kind {
     return reflect.Invalid
 }
 
+// !!set
+// the set is decoded to a mapping with generic values, which must be null
+type SetState struct {
+    startRv *reflect.Value  // the set (a map of empty struct or bool values)
+    t TagHandler
+    anchor *string
+    rvm reflect.Value       // the mapping the set is decoded to
+    inner CollectionWrapper // the mapping state decoding the set
+}
+
+// the ObjectWrapper interface
+func (s *SetState) StartRV() *reflect.Value {
+    return s.startRv
+}
+
+func (s *SetState) Anchor() *string {
+    return s.anchor
+}
+
+func (s *SetState) TagHandler() TagHandler {
+    return s.t
+}
+
+func (s *SetState) SchemaImplementer() SchemaImplementer {
+    return s.t.SchemaImplementer()
+}
+
+// the CollectionWrapper interface (forwarded to the mapping state)
+func (s *SetState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, error) {
+    return s.inner.ObjStartIn(event, path)
+}
+
+func (s *SetState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) error {
+    return s.inner.ObjEndIn(event, path, ow)
+}
+
+func (s *SetState) CollectionStart(event *Event, path *Path) error {
+    return s.inner.CollectionStart(event, path)
+}
+
+func (s *SetState) CollectionEnd(event *Event, path *Path) error {
+
+    if err := s.inner.CollectionEnd(event, path); err != nil {
+        return err
+    }
+
+    rv := s.startRv
+    keys := s.rvm.MapKeys()
+
+    for _, key := range keys {
+        if value := s.rvm.MapIndex(key); !value.IsNil() {
+            return errors.New(fmt.Sprintf("%v: set member %v with non null value", path, key))
+        }
+    }
+
+    var st reflect.Type
+    if rv.Kind() == reflect.Interface {
+        // tighten the key type if uniform, as with generic mappings
+        kt := genericIfaceType
+        for idx, key := range keys {
+            if key.IsNil() {
+                kt = genericIfaceType
+                break
+            }
+            if idx == 0 {
+                kt = key.Elem().Type()
+            } else if key.Elem().Type() != kt {
+                kt = genericIfaceType
+                break
+            }
+        }
+        st = reflect.MapOf(kt, emptyStructType)
+    } else {
+        st = rv.Type()
+    }
+
+    sv := reflect.MakeMapWithSize(st, len(keys))
+
+    // the members are either empty structs or true
+    member := reflect.New(st.Elem()).Elem()
+    if member.Kind() == reflect.Bool {
+        member.SetBool(true)
+    }
+
+    // unwrap the generic keys if tightened
+    unwrap := s.rvm.Type().Key() == genericIfaceType && st.Key() != genericIfaceType
+
+    for _, key := range keys {
+        if unwrap {
+            key = key.Elem()
+        }
+        sv.SetMapIndex(key, member)
+    }
+
+    rv.Set(sv)
+
+    return nil
+}
+
+func (s *SetState) CurrentAddress(path *Path) AddressWrapper {
+    return s.inner.CurrentAddress(path)
+}
+
+type SetTag struct {
+    si SchemaImplementer
+}
+
+func (t *SetTag) Tag() string {
+    return DefaultLongTagPrefix + "set"
+}
+
+func (t *SetTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *SetTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
+
+func (t *SetTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+
+    s := &SetState{
+        startRv: startRv,
+        t: t,
+        anchor: event.AnchorString(),
+    }
+
+    // sets are maps of empty structs or bools (and generics)
+    switch kind := startRv.Kind(); kind {
+    case reflect.Interface:
+        s.rvm = reflect.New(genericMapType).Elem()
+
+    case reflect.Map:
+        if !isSetType(startRv.Type()) && startRv.Type().Elem().Kind() != reflect.Bool {
+            return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), startRv.Type()))
+        }
+        s.rvm = reflect.New(reflect.MapOf(startRv.Type().Key(), genericIfaceType)).Elem()
+
+    default:
+        return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
+    }
+
+    inner, err := NewMappingStateDefault(event, path, &s.rvm, t)
+    if err != nil {
+        return nil, err
+    }
+    s.inner = inner
+
+    return s, nil
+}
+
+func (t *SetTag) Specify(kind reflect.Kind) reflect.Kind {
+
+    switch kind {
+    case reflect.Interface, reflect.Map:
+        return reflect.Map
+    }
+    return reflect.Invalid
+}
+
+// !!omap and !!pairs
+// the items are decoded to ordered mappings, which must be single pairs
+type PairsState struct {
+    startRv *reflect.Value  // the pairs (a slice of MapItem)
+    t TagHandler
+    anchor *string
+    unique bool             // keys must be unique (!!omap)
+    gt reflect.Type         // the type of generic values
+    rvs reflect.Value       // the single pair mappings of the items
+    inner CollectionWrapper // the sequence state decoding the items
+}
+
+// the ObjectWrapper interface
+func (s *PairsState) StartRV() *reflect.Value {
+    return s.startRv
+}
+
+func (s *PairsState) Anchor() *string {
+    return s.anchor
+}
+
+func (s *PairsState) TagHandler() TagHandler {
+    return s.t
+}
+
+func (s *PairsState) SchemaImplementer() SchemaImplementer {
+    return s.t.SchemaImplementer()
+}
+
+// the CollectionWrapper interface (forwarded to the sequence state)
+func (s *PairsState) ObjStartIn(event *Event, path *Path) (ObjectWrapper, error) {
+
+    if et := event.Type(); et != MappingStart && et != Alias {
+        return nil, errors.New(fmt.Sprintf("%v: %s items must be mappings", path, s.t.Tag()))
+    }
+    return s.inner.ObjStartIn(event, path)
+}
+
+func (s *PairsState) ObjEndIn(event *Event, path *Path, ow ObjectWrapper) error {
+    return s.inner.ObjEndIn(event, path, ow)
+}
+
+func (s *PairsState) CollectionStart(event *Event, path *Path) error {
+    return s.inner.CollectionStart(event, path)
+}
+
+func (s *PairsState) CollectionEnd(event *Event, path *Path) error {
+
+    if err := s.inner.CollectionEnd(event, path); err != nil {
+        return err
+    }
+
+    rv := s.startRv
+
+    pt := s.gt
+    if rv.Kind() != reflect.Interface {
+        pt = rv.Type()
+    }
+
+    n := s.rvs.Len()
+    sv := reflect.MakeSlice(pt, 0, n)
+    dup := make(map[interface{}]uvoid)
+
+    for idx := 0; idx < n; idx++ {
+
+        ms := s.rvs.Index(idx).Interface().(MapSlice)
+        if len(ms) != 1 {
+            return errors.New(fmt.Sprintf("%v: %s item %d is not a single pair mapping", path, s.t.Tag(), idx))
+        }
+        item := ms[0]
+
+        if s.unique {
+            okey, err := orderedKey(reflect.ValueOf(&item.Key).Elem())
+            if err != nil {
+                return fmt.Errorf("%v: %w", path, err)
+            }
+            if _, exists := dup[okey]; exists {
+                return errors.New(fmt.Sprintf("%v: duplicate key %v on %s", path, okey, s.t.Tag()))
+            }
+            dup[okey] = uvoid{}
+        }
+
+        sv = reflect.Append(sv, reflect.ValueOf(item))
+    }
+
+    rv.Set(sv)
+
+    return nil
+}
+
+func (s *PairsState) CurrentAddress(path *Path) AddressWrapper {
+    return s.inner.CurrentAddress(path)
+}
+
+func newPairsState(event *Event, path *Path, startRv *reflect.Value, t TagHandler, unique bool, gt reflect.Type) (ObjectWrapper, error) {
+
+    // only slices of key/value pairs (and generics)
+    if kind := startRv.Kind(); kind != reflect.Interface && (kind != reflect.Slice || !isPairsType(startRv.Type())) {
+        return nil, errors.New(fmt.Sprintf("%s: Cannot store a %s to a %v", path, t.Tag(), kind))
+    }
+
+    s := &PairsState{
+        startRv: startRv,
+        t: t,
+        anchor: event.AnchorString(),
+        unique: unique,
+        gt: gt,
+        rvs: reflect.New(reflect.SliceOf(mapSliceType)).Elem(),
+    }
+
+    inner, err := NewSequenceStateDefault(event, path, &s.rvs, t)
+    if err != nil {
+        return nil, err
+    }
+    s.inner = inner
+
+    return s, nil
+}
+
+type OmapTag struct {
+    si SchemaImplementer
+}
+
+func (t *OmapTag) Tag() string {
+    return DefaultLongTagPrefix + "omap"
+}
+
+func (t *OmapTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *OmapTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
+
+func (t *OmapTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+    return newPairsState(event, path, startRv, t, true, omapType)
+}
+
+func (t *OmapTag) Specify(kind reflect.Kind) reflect.Kind {
+
+    switch kind {
+    case reflect.Interface, reflect.Slice:
+        return reflect.Slice
+    }
+    return reflect.Invalid
+}
+
+type PairsTag struct {
+    si SchemaImplementer
+}
+
+func (t *PairsTag) Tag() string {
+    return DefaultLongTagPrefix + "pairs"
+}
+
+func (t *PairsTag) SetSchemaImplementer(si SchemaImplementer) {
+    t.si = si
+}
+
+func (t *PairsTag) SchemaImplementer() SchemaImplementer {
+    return t.si
+}
+
+func (t *PairsTag) NewSchemaObject(event *Event, path *Path, startRv *reflect.Value, si SchemaImplementer) (ObjectWrapper, error) {
+    return newPairsState(event, path, startRv, t, false, pairsType)
+}
+
+func (t *PairsTag) Specify(kind reflect.Kind) reflect.Kind {
+
+    switch kind {
+    case reflect.Interface, reflect.Slice:
+        return reflect.Slice
+    }
+    return reflect.Invalid
+}
+
 ////////////////////////////////////////////////////////
 
 // the failsafe schema
@@ -49,6 +49,12 @@ type TagHandler interface {
     Specify(kind reflect.Kind) reflect.Kind
 }
 
+// tag handlers of scalars that store the text without an event
+// (i.e. when converting values that are already decoded)
+type ScalarTextSetter interface {
+    SetScalarText(rv reflect.Value, vp *string) error
+}
+
 // just object creator
 type SchemaObjectCreator interface {
     NewSchemaObject(event *Event, path *Path, startRv *reflect.Value) (ObjectWrapper, error)
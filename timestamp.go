@@ -0,0 +1,86 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// the YAML timestamp forms; a date only, or a date and time
+// with an optional fraction and an optional time zone
+var timestampDateRe = regexp.MustCompile(`^([0-9]{4})-([0-9]{2})-([0-9]{2})$`)
+var timestampRe = regexp.MustCompile(`^([0-9]{4})-([0-9]{1,2})-([0-9]{1,2})` +
+                                     `(?:[Tt]|[ \t]+)([0-9]{1,2}):([0-9]{2}):([0-9]{2})` +
+                                     `(?:\.([0-9]*))?` +
+                                     `(?:[ \t]*(Z|[-+][0-9]{1,2}(?::?[0-9]{2})?))?$`)
+
+// true if the string has the form of a timestamp
+func isTimestamp(str string) bool {
+    return timestampDateRe.MatchString(str) || timestampRe.MatchString(str)
+}
+
+// parse any of the YAML timestamp forms; without a time zone the time is UTC
+func parseTimestamp(str string) (time.Time, error) {
+
+    var m []string
+    if m = timestampDateRe.FindStringSubmatch(str); m == nil {
+        if m = timestampRe.FindStringSubmatch(str); m == nil {
+            return time.Time{}, errors.New(fmt.Sprintf("invalid timestamp %s", str))
+        }
+    }
+
+    // all the numeric fields are validated by the regexp
+    var f [6]int
+    for i := 1; i < len(m) && i <= len(f); i++ {
+        f[i - 1], _ = strconv.Atoi(m[i])
+    }
+
+    nsec := 0
+    loc := time.UTC
+
+    if len(m) > 7 {
+        // the fraction, to nanoseconds
+        if frac := m[7]; frac != "" {
+            if len(frac) > 9 {
+                frac = frac[:9]
+            }
+            nsec, _ = strconv.Atoi(frac + strings.Repeat("0", 9 - len(frac)))
+        }
+
+        // the time zone, as an offset
+        if tz := m[8]; tz != "" && tz != "Z" {
+            sign := 1
+            if tz[0] == '-' {
+                sign = -1
+            }
+            // [+-]h, [+-]hh, [+-]hhmm or [+-]hh:mm
+            digits := strings.Replace(tz[1:], ":", "", 1)
+            hours, mins := 0, 0
+            if len(digits) <= 2 {
+                hours, _ = strconv.Atoi(digits)
+            } else {
+                hours, _ = strconv.Atoi(digits[:len(digits) - 2])
+                mins, _ = strconv.Atoi(digits[len(digits) - 2:])
+            }
+            loc = time.FixedZone("", sign * (hours * 3600 + mins * 60))
+        }
+    }
+
+    t := time.Date(f[0], time.Month(f[1]), f[2], f[3], f[4], f[5], nsec, loc)
+
+    // time.Date normalizes, so out of range fields show up as a mismatch
+    if t.Year() != f[0] || int(t.Month()) != f[1] || t.Day() != f[2] ||
+       t.Hour() != f[3] || t.Minute() != f[4] || t.Second() != f[5] {
+        return time.Time{}, errors.New(fmt.Sprintf("invalid timestamp %s", str))
+    }
+
+    return t, nil
+}
@@ -0,0 +1,167 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "testing"
+    "time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+
+    est := time.FixedZone("", -5 * 3600)
+
+    tests := []struct {
+        str string
+        expected time.Time
+        fails bool
+    }{
+        { str: "2002-12-14", expected: time.Date(2002, 12, 14, 0, 0, 0, 0, time.UTC) },
+        { str: "2001-12-14t21:59:43.10-05:00", expected: time.Date(2001, 12, 14, 21, 59, 43, 100000000, est) },
+        { str: "2001-12-14 21:59:43.10 -5", expected: time.Date(2001, 12, 14, 21, 59, 43, 100000000, est) },
+        { str: "2001-12-15T02:59:43.1Z", expected: time.Date(2001, 12, 15, 2, 59, 43, 100000000, time.UTC) },
+        { str: "2001-12-15 2:59:43.10", expected: time.Date(2001, 12, 15, 2, 59, 43, 100000000, time.UTC) },
+        { str: "2001-1-5 10:00:00 +0530", expected: time.Date(2001, 1, 5, 4, 30, 0, 0, time.UTC) },
+        { str: "2001-12-14T21:59:43.123456789123Z", expected: time.Date(2001, 12, 14, 21, 59, 43, 123456789, time.UTC) },
+        { str: "2001-12-14T21:59:43.Z", expected: time.Date(2001, 12, 14, 21, 59, 43, 0, time.UTC) },
+        { str: "2001-1-5", fails: true },
+        { str: "2001-13-01", fails: true },
+        { str: "2001-02-30", fails: true },
+        { str: "2001-12-14T25:00:00Z", fails: true },
+        { str: "2001-12-14T21:59", fails: true },
+        { str: "20011214", fails: true },
+        { str: "today", fails: true },
+    }
+
+    for _, tt := range tests {
+        ts, err := parseTimestamp(tt.str)
+        if tt.fails {
+            if err == nil {
+                t.Errorf("%s: expected an error, got %v", tt.str, ts)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tt.str, err)
+            continue
+        }
+        if !ts.Equal(tt.expected) {
+            t.Errorf("%s: got %v, expected %v", tt.str, ts, tt.expected)
+        }
+        if !isTimestamp(tt.str) {
+            t.Errorf("%s: not a timestamp", tt.str)
+        }
+    }
+}
+
+type timed struct {
+    When time.Time `yaml:"when"`
+    Every time.Duration `yaml:"every"`
+}
+
+func TestDecodeTimestamps(t *testing.T) {
+
+    when := time.Date(2001, 12, 14, 21, 59, 43, 100000000, time.UTC)
+    date := time.Date(2002, 12, 14, 0, 0, 0, 0, time.UTC)
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "typed",
+            input: "when: 2001-12-14T21:59:43.1Z\nevery: 1h30m\n",
+            into: new(timed),
+            expected: timed{When: when, Every: 90 * time.Minute},
+        }, {
+            name: "date",
+            input: "when: 2002-12-14\n",
+            into: new(timed),
+            expected: timed{When: date},
+        }, {
+            name: "quoted",
+            input: "when: '2002-12-14'\n",
+            into: new(timed),
+            expected: timed{When: date},
+        }, {
+            name: "implicit in 1.1",
+            input: "%YAML 1.1\n---\n[2002-12-14, 2001-12-14 21:59:43.10 Z]\n",
+            into: new(interface{}),
+            expected: []interface{}{date, when},
+        }, {
+            name: "a string in core",
+            input: "[2002-12-14]\n",
+            into: new(interface{}),
+            expected: []interface{}{"2002-12-14"},
+        }, {
+            name: "implicit in core with the option",
+            input: "[2002-12-14]\n",
+            opts: []interface{}{"timestamps"},
+            into: new(interface{}),
+            expected: []interface{}{date},
+        }, {
+            name: "explicit tag",
+            input: "!!timestamp 2002-12-14\n",
+            into: new(interface{}),
+            expected: date,
+        }, {
+            name: "a quoted one is a string",
+            input: "%YAML 1.1\n---\n['2002-12-14']\n",
+            into: new(interface{}),
+            expected: []interface{}{"2002-12-14"},
+        }, {
+            name: "invalid date",
+            input: "when: 2002-13-14\n",
+            into: new(timed),
+            fails: true,
+        }, {
+            name: "not a timestamp",
+            input: "when: tomorrow\n",
+            into: new(timed),
+            fails: true,
+        }, {
+            name: "invalid duration",
+            input: "every: often\n",
+            into: new(timed),
+            fails: true,
+        }, {
+            name: "explicit tag on an invalid value",
+            input: "!!timestamp yesterday\n",
+            into: new(interface{}),
+            fails: true,
+        },
+    })
+}
+
+func TestMarshalTimestamps(t *testing.T) {
+
+    est := time.FixedZone("", -5 * 3600)
+    value := timed{When: time.Date(2001, 12, 14, 21, 59, 43, 100000000, time.UTC), Every: 90 * time.Second}
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "round trip",
+            value: value,
+            back: new(timed),
+            expected: value,
+        }, {
+            name: "json",
+            value: value,
+            opts: []interface{}{"output-mode=json"},
+            back: new(timed),
+            expected: value,
+        }, {
+            name: "text forms",
+            value: value,
+            back: new(map[string]string),
+            expected: map[string]string{"when": "2001-12-14T21:59:43.1Z", "every": "1m30s"},
+        }, {
+            name: "with a time zone",
+            value: []time.Time{time.Date(2001, 12, 14, 21, 59, 43, 0, est)},
+            back: new([]string),
+            expected: []string{"2001-12-14T21:59:43-05:00"},
+        }, {
+            name: "implicit in 1.1",
+            value: []interface{}{value.When},
+            opts: []interface{}{"schema=1.1"},
+            back: new([]interface{}),
+            expected: []interface{}{value.When},
+        },
+    })
+}
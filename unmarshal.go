@@ -3,6 +3,9 @@ package fyaml
 
 import (
     "fmt"
+    "io"
+    "os"
+    "strings"
     "unsafe"
     "errors"
     gopointer "github.com/mattn/go-pointer"
@@ -14,61 +17,207 @@ import (
 */
 import "C"
 
-func (dec *Decoder) unmarshalInternal(data []byte, filename string, v interface{}) error {
+// release the parser and the input data (if any)
+func (dec *Decoder) inputReset() {
+
+    if dec.p != nil {
+        dec.p.Destroy()
+        dec.p = nil
+    }
+
+    if dec.data != nil {
+        dec.Free(dec.data)
+        dec.data = nil
+    }
+
+    // the parser is gone, so no more callbacks
+    if dec.rp != nil {
+        gopointer.Unref(dec.rp)
+        dec.rp = nil
+    }
+    dec.r = nil
+
+    dec.docEnd = false
+    dec.streamEnd = false
+    dec.ierr = nil
+    dec.name = ""
+    dec.nread = 0
+    dec.rerr = nil
+}
+
+// create a fresh parser for a new input
+func (dec *Decoder) inputCreate() (*Parser, error) {
+
+    // drop any previous input
+    dec.inputReset()
 
     // create the parser object
     p, err := ParserCreate(dec, dec.opts)
+    if err != nil {
+        return nil, err
+    }
+
+    dec.p = p
+
+    return p, nil
+}
+
+// bind the decoder to in memory data; any previous input is dropped
+func (dec *Decoder) SetInputData(data []byte) error {
+
+    if max := dec.opts.MaxInputBytes; max > 0 && len(data) > max {
+        return limitError("maximum input bytes", max)
+    }
+
+    p, err := dec.inputCreate()
     if err != nil {
         return err
     }
-    defer p.Destroy()
 
-    if data != nil {
-        // allocate the slice memory on the C side
-        dataCopyC := dec.Allocate(len(data))
-        defer dec.Free(dataCopyC)
+    // allocate the slice memory on the C side
+    // (note that the allocation must be at least one byte)
+    size := len(data)
+    if size == 0 {
+        size = 1
+    }
+    dec.data = dec.Allocate(size)
 
-        // make a go slice and copy the data there
-        dataCopy := unsafe.Slice((*byte)(dataCopyC), len(data))
-        copy(dataCopy, data)
+    // make a go slice and copy the data there
+    dataCopy := unsafe.Slice((*byte)(dec.data), len(data))
+    copy(dataCopy, data)
 
-        // and let it rip with the slice copy at the C side
-        if err := p.SetInputData(dataCopyC, uint(len(data))); err != nil {
-            return err
-        }
-    } else if filename != "" {
+    // and let it rip with the slice copy at the C side
+    if err := p.SetInputData(dec.data, uint(len(data))); err != nil {
+        dec.inputReset()
+        return err
+    }
+
+    dec.name = "<data>"
 
-        // use the file
-        if err := p.SetInputFile(filename); err != nil {
+    return nil
+}
+
+// bind the decoder to a file; any previous input is dropped
+func (dec *Decoder) SetInputFile(filename string) error {
+
+    if max := dec.opts.MaxInputBytes; max > 0 {
+        fi, err := os.Stat(filename)
+        if err != nil {
             return err
         }
+        if fi.Size() > int64(max) {
+            return limitError("maximum input bytes", max)
+        }
+    }
 
-    } else {
-        return errors.New(fmt.Sprintf("failed to find unarshal method"))
+    p, err := dec.inputCreate()
+    if err != nil {
+        return err
+    }
+
+    // use the file
+    if err := p.SetInputFile(filename); err != nil {
+        dec.inputReset()
+        return err
+    }
+
+    dec.name = filename
+
+    return nil
+}
+
+// bind the decoder to a reader; any previous input is dropped
+// the reader is consumed as parsing progresses, not upfront
+func (dec *Decoder) SetInputReader(r io.Reader) error {
+
+    if r == nil {
+        return errors.New("nil reader for input")
+    }
+
+    p, err := dec.inputCreate()
+    if err != nil {
+        return err
+    }
+
+    dec.r = r
+    dec.rp = gopointer.Save(dec)
+
+    // feed the parser through the input callback
+    if err := p.SetInputCallback(dec.rp); err != nil {
+        dec.inputReset()
+        return err
+    }
+
+    dec.name = "<stream>"
+
+    return nil
+}
+
+// decode the next document of the bound input to v
+// returns io.EOF when there are no more documents
+func (dec *Decoder) Decode(v interface{}) error {
+
+    if dec.p == nil {
+        return errors.New("decoder is not bound to an input")
+    }
+
+    // once an error is hit, the stream position is unknown
+    if dec.ierr != nil {
+        return dec.ierr
+    }
+
+    if dec.streamEnd {
+        return io.EOF
     }
 
     dec.err = nil
     dec.si = nil
     dec.root = v
+    dec.docEnd = false
+    dec.nodes = 0
+    dec.frames = nil
+    dec.weights = make(map[string]int)
+    dec.expanded = 0
+    dec.pathCW = nil
+    dec.pathFound = false
 
     // get a pointer for the unmarshaler object
     cp := gopointer.Save(dec)
     defer gopointer.Unref(cp)
 
     // note we don't abstract this internal parser interface
-    rc := C.fy_parse_compose(p.C(), C.fy_parse_composer_cb(C.compose_process_event), cp)
+    // the composer stops at the end of each document, and
+    // resumes from there on the next call
+    rc := C.fy_parse_compose(dec.p.C(), C.fy_parse_composer_cb(C.compose_process_event), cp)
 
     // is there a processor error get it and clear
-    err = dec.err
+    err := dec.err
     dec.err = nil
 
+    // no processor error, parser error?
+    if err == nil && !bool(C.fy_composer_return_is_ok(C.enum_fy_composer_return(rc))) {
+        // report what the parser had to say
+        if dl := dec.p.Diag().Diagnostics(dec.name); len(dl) > 0 {
+            err = dl
+        } else {
+            err = errors.New(fmt.Sprintf("Failed on compose"))
+        }
+    }
+
     if err != nil {
+        dec.ierr = err
         return err
     }
 
-    // no processor error, parser error?
-    if !bool(C.fy_composer_return_is_ok(C.enum_fy_composer_return(rc))) {
-        return errors.New(fmt.Sprintf("Failed on compose"))
+    // no document found, we're at the end
+    if !dec.docEnd {
+        dec.streamEnd = true
+        return io.EOF
+    }
+
+    // the rest of the stream is fine, only this document is missing the path
+    if dec.opts.Path != "" && !dec.pathFound {
+        return errors.New(fmt.Sprintf("%s: path %s not found", dec.name, dec.opts.Path))
     }
 
     dec.Debugf("return root: %T\n", v)
@@ -76,6 +225,33 @@ func (dec *Decoder) unmarshalInternal(data []byte, filename string, v interface{
     return nil
 }
 
+// unmarshal the first document of the input
+// note that this drops any input the decoder was bound to
+func (dec *Decoder) unmarshalInternal(data []byte, filename string, v interface{}) error {
+
+    var err error
+
+    if data != nil {
+        err = dec.SetInputData(data)
+    } else if filename != "" {
+        err = dec.SetInputFile(filename)
+    } else {
+        return errors.New(fmt.Sprintf("failed to find unarshal method"))
+    }
+
+    if err != nil {
+        return err
+    }
+    defer dec.inputReset()
+
+    // an empty stream leaves v untouched
+    if err = dec.Decode(v); err != nil && err != io.EOF {
+        return err
+    }
+
+    return nil
+}
+
 func (dec *Decoder) Unmarshal(data []byte, v interface{}) error {
     return dec.unmarshalInternal(data, "", v)
 }
@@ -154,14 +330,21 @@ func (dec *Decoder) CollectionCreate(event *Event, path *Path) error {
     case SequenceStart, MappingStart:
         // the prolog for the object
 
-        cw = path.ParentUserData().(CollectionWrapper)
-        ow, err = cw.ObjStartIn(event, path)
+        pcw, isPath := dec.parentCollection(event, path)
+        if pcw == nil {
+            // outside of the path
+            return nil
+        }
+        ow, err = pcw.ObjStartIn(event, path)
         if err != nil {
             return err
         }
 
         // the object is a collection
         cw = ow.(CollectionWrapper)
+        if isPath {
+            dec.pathCW = cw
+        }
 
     default:
         panic(fmt.Sprintf("CollectionCreate() with %s event not allowed", event.Type()))
@@ -198,10 +381,20 @@ func (dec *Decoder) CollectionDestroy(event *Event, path *Path) error {
         cw = path.RootUserData().(CollectionWrapper)
 
     case SequenceEnd:
-        cw = path.LastComponent().SequenceUserData().(CollectionWrapper)
+        ud := path.LastComponent().SequenceUserData()
+        if ud == nil {
+            // outside of the path
+            return nil
+        }
+        cw = ud.(CollectionWrapper)
 
     case MappingEnd:
-        cw = path.LastComponent().MappingUserData().(CollectionWrapper)
+        ud := path.LastComponent().MappingUserData()
+        if ud == nil {
+            // outside of the path
+            return nil
+        }
+        cw = ud.(CollectionWrapper)
 
     default:
         panic(fmt.Sprintf("CollectionDestroy() with %s event not allowed", event.Type()))
@@ -222,7 +415,7 @@ func (dec *Decoder) CollectionDestroy(event *Event, path *Path) error {
         path.SetRootUserData(nil)
 
     case SequenceEnd:
-        pcw := path.ParentUserData().(CollectionWrapper)
+        pcw := dec.endParentCollection(path, cw)
         if err := pcw.ObjEndIn(event, path, cw); err != nil {
             return err
         }
@@ -230,7 +423,7 @@ func (dec *Decoder) CollectionDestroy(event *Event, path *Path) error {
         path.LastComponent().SetSequenceUserData(nil)
 
     case MappingEnd:
-        pcw := path.ParentUserData().(CollectionWrapper)
+        pcw := dec.endParentCollection(path, cw)
         if err := pcw.ObjEndIn(event, path, cw); err != nil {
             return err
         }
@@ -250,7 +443,11 @@ func (dec *Decoder) Scalar(event *Event, path *Path) error {
     var ow ObjectWrapper
     var sw ScalarWrapper
 
-    cw := path.ParentUserData().(CollectionWrapper)
+    cw, _ := dec.parentCollection(event, path)
+    if cw == nil {
+        // outside of the path
+        return nil
+    }
 
     // the prolog for the object
     ow, err = cw.ObjStartIn(event, path)
@@ -271,6 +468,126 @@ func (dec *Decoder) Scalar(event *Event, path *Path) error {
     return cw.ObjEndIn(event, path, ow)
 }
 
+// the path option in the form of the path text of the events
+func (dec *Decoder) pathText() string {
+    text := dec.opts.Path
+    if !strings.HasPrefix(text, "/") {
+        text = "/" + text
+    }
+    return strings.TrimSuffix(text, "/")
+}
+
+// the collection an object starts in; true for the node at the path option
+// with the path option only that node is decoded (to the root), along with
+// the anchored nodes that aliases in it might refer to; the rest is
+// skipped and nil is returned for it
+func (dec *Decoder) parentCollection(event *Event, path *Path) (CollectionWrapper, bool) {
+
+    if dec.opts.Path == "" {
+        return path.ParentUserData().(CollectionWrapper), false
+    }
+
+    if !dec.pathFound && !path.InMappingKey() && strings.TrimSuffix(path.String(), "/") == dec.pathText() {
+        dec.pathFound = true
+        return path.RootUserData().(CollectionWrapper), true
+    }
+
+    // inside the node at the path (or an anchored one)
+    if cw, isCw := path.ParentUserData().(CollectionWrapper); isCw && !path.InRoot() {
+        return cw, false
+    }
+
+    if event.AnchorString() != nil {
+        return &DetachedState{}, false
+    }
+
+    return nil, false
+}
+
+// the collection an object ends in (see parentCollection)
+func (dec *Decoder) endParentCollection(path *Path, cw CollectionWrapper) CollectionWrapper {
+
+    if dec.opts.Path == "" {
+        return path.ParentUserData().(CollectionWrapper)
+    }
+
+    if cw == dec.pathCW {
+        dec.pathCW = nil
+        return path.RootUserData().(CollectionWrapper)
+    }
+
+    if pcw, isCw := path.ParentUserData().(CollectionWrapper); isCw && !path.InRoot() {
+        return pcw
+    }
+
+    return &DetachedState{}
+}
+
+// enforce the node and depth limits of the options
+func (dec *Decoder) checkLimits(event *Event, path *Path) error {
+
+    // the document start is not a node
+    if event.Type() == DocumentStart {
+        return nil
+    }
+
+    // an alias counts as the nodes of the subtree it refers to
+    if event.Type() == Alias {
+        weight := dec.weights[event.Token().Text()]
+        if weight == 0 {
+            // not anchored (yet), the decoder reports it
+            weight = 1
+        }
+        dec.nodes += weight
+        dec.expanded += weight
+        if max := dec.opts.MaxAliasExpansion; max > 0 && dec.expanded > max {
+            return limitError("maximum alias expansion", max)
+        }
+    } else {
+        dec.nodes++
+    }
+
+    if max := dec.opts.MaxNodes; max > 0 && dec.nodes > max {
+        return limitError("maximum nodes", max)
+    }
+
+    switch event.Type() {
+    case Scalar:
+        if anchor := event.AnchorString(); anchor != nil {
+            dec.weights[*anchor] = 1
+        }
+
+    case SequenceStart, MappingStart:
+        if max := dec.opts.MaxDepth; max > 0 && path.Depth() > max {
+            return limitError("maximum depth", max)
+        }
+        frame := limitFrame{start: -1}
+        if anchor := event.AnchorString(); anchor != nil {
+            frame.anchor, frame.start = *anchor, dec.nodes - 1
+        }
+        dec.frames = append(dec.frames, frame)
+    }
+
+    return nil
+}
+
+// the weight of an anchored collection is known at its end
+func (dec *Decoder) endLimits(event *Event) {
+
+    switch event.Type() {
+    case SequenceEnd, MappingEnd:
+        n := len(dec.frames) - 1
+        if n < 0 {
+            return
+        }
+        frame := dec.frames[n]
+        dec.frames = dec.frames[:n]
+        if frame.start >= 0 {
+            dec.weights[frame.anchor] = dec.nodes - frame.start
+        }
+    }
+}
+
 func (dec *Decoder) ProcessEvent(event *Event, path *Path) (bool, error) {
 
     dec.Debugf("%v: %v\n", event, path)
@@ -278,26 +595,41 @@ func (dec *Decoder) ProcessEvent(event *Event, path *Path) (bool, error) {
     var err error = nil
 
     switch et := event.Type(); et {
-    case StreamStart, StreamEnd:
+    case StreamStart:
         // nothing for now
         return false, nil
 
+    case StreamEnd:
+        // no more documents
+        dec.streamEnd = true
+        return true, nil
+
     case Scalar, Alias:
-        err = dec.Scalar(event, path)
+        if err = dec.checkLimits(event, path); err == nil {
+            err = dec.Scalar(event, path)
+        }
 
     case DocumentStart, SequenceStart, MappingStart:
-        err = dec.CollectionCreate(event, path)
+        if err = dec.checkLimits(event, path); err == nil {
+            err = dec.CollectionCreate(event, path)
+        }
 
     case DocumentEnd, SequenceEnd, MappingEnd:
+        dec.endLimits(event)
         err = dec.CollectionDestroy(event, path)
 
-        // for document end, stop now, no multi documents yet
+        // for document end, stop now; Decode() picks up from here
         if et == DocumentEnd && err == nil {
+            dec.docEnd = true
             return true, nil
         }
     }
 
     if err != nil {
+        // point to the location of the error
+        if _, isDe := err.(*DecodeError); !isDe {
+            err = NewDecodeError(dec.name, event, path, err)
+        }
         return true, err
     }
 
@@ -0,0 +1,574 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+)
+
+// a decoding test; into is a pointer to the value to decode to
+type decodeTest struct {
+    name string
+    input string
+    opts []interface{}
+    into interface{}
+    expected interface{}
+    fails bool
+    err error               // the error expected to be wrapped (if any)
+}
+
+func runDecodeTests(t *testing.T, tests []decodeTest) {
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            err := Unmarshal([]byte(tt.input), tt.into, tt.opts...)
+            if tt.err != nil {
+                if !errors.Is(err, tt.err) {
+                    t.Fatalf("expected %v, got %v", tt.err, err)
+                }
+                return
+            }
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got %#v", reflect.ValueOf(tt.into).Elem().Interface())
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            got := reflect.ValueOf(tt.into).Elem().Interface()
+            if !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %#v, expected %#v", got, tt.expected)
+            }
+        })
+    }
+}
+
+type keyPoint struct {
+    X int `yaml:"x"`
+    Y int `yaml:"y"`
+}
+
+func TestDecodeComplexKeys(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "sequence key to generic map",
+            input: "? [a, b]\n: v\n",
+            into: new(map[interface{}]interface{}),
+            expected: map[interface{}]interface{}{ [2]interface{}{"a", "b"}: "v" },
+        }, {
+            name: "mapping key to generic map",
+            input: "? {x: 1}\n: v\n",
+            into: new(map[interface{}]interface{}),
+            expected: map[interface{}]interface{}{ ComplexKey(`{"x": 1}`): "v" },
+        }, {
+            name: "nested sequence key",
+            input: "? [a, [b, c]]\n: v\n",
+            into: new(interface{}),
+            expected: map[interface{}]interface{}{
+                [2]interface{}{"a", [2]interface{}{"b", "c"}}: "v",
+            },
+        }, {
+            name: "sequence key to array keyed map",
+            input: "? [a, b]\n: v\n",
+            into: new(map[[2]string]string),
+            expected: map[[2]string]string{ {"a", "b"}: "v" },
+        }, {
+            name: "mapping key to struct keyed map",
+            input: "? {x: 1, y: 2}\n: v\n",
+            into: new(map[keyPoint]string),
+            expected: map[keyPoint]string{ {X: 1, Y: 2}: "v" },
+        }, {
+            name: "complex key to struct",
+            input: "? [a]\n: v\n",
+            into: new(keyPoint),
+            fails: true,
+        }, {
+            name: "complex key to string keyed map",
+            input: "? [a]\n: v\n",
+            into: new(map[string]string),
+            fails: true,
+        }, {
+            name: "too long for the array key",
+            input: "? [a, b, c]\n: v\n",
+            into: new(map[[2]string]string),
+            fails: true,
+        },
+    })
+}
+
+func TestDecodeStructTags(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "yaml tags",
+            input: "y_both: 1\nj_only: 2\ny_only: 3\nnone: 4\n",
+            into: new(tagged),
+            expected: tagged{Both: 1, JSON: 2, YAML: 3, None: 4},
+        }, {
+            name: "json tags first",
+            input: "j_both: 1\nj_skip: 2\nj_empty: 3\n",
+            opts: []interface{}{"struct-tag=json,yaml"},
+            into: new(tagged),
+            expected: tagged{Both: 1, Skip: 2, Empty: 3},
+        }, {
+            name: "ignored field",
+            input: "Skip: 1\n",
+            into: new(tagged),
+            fails: true,
+        }, {
+            name: "the json name when yaml has precedence",
+            input: "j_both: 1\n",
+            into: new(tagged),
+            fails: true,
+        }, {
+            name: "bad struct tag option",
+            input: "None: 1\n",
+            opts: []interface{}{"struct-tag="},
+            into: new(tagged),
+            fails: true,
+        },
+    })
+}
+
+func TestDecodeEmbeddedFields(t *testing.T) {
+
+    var expected embedding
+    expected.ID = 1
+    expected.Meta = &Meta{Note: "n"}
+    expected.Name = "x"
+    expected.Spec.Size = 3
+    expected.Rest = map[string]interface{}{"kind": "k", "other": []interface{}{1}}
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "flattened",
+            input: "id: 1\nnote: n\nname: x\nsize: 3\nkind: k\nother: [1]\n",
+            into: new(embedding),
+            expected: expected,
+        }, {
+            name: "nil embedded pointer is not allocated",
+            input: "id: 1\n",
+            into: new(embedding),
+            expected: embedding{Base: Base{ID: 1}},
+        }, {
+            name: "duplicate key",
+            input: "id: 1\nid: 2\n",
+            into: new(embedding),
+            fails: true,
+        }, {
+            name: "unknown key without an inline map",
+            input: "id: 1\nother: 2\n",
+            into: new(Base),
+            fails: true,
+        },
+    })
+}
+
+type customized struct {
+    Name upper `yaml:"name"`
+    Temp temperature `yaml:"temp"`
+    Level level `yaml:"level"`
+}
+
+func TestDecodeCustom(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "unmarshalers",
+            input: "name: abc\ntemp: 21.5\nlevel: low\n",
+            into: new(customized),
+            expected: customized{Name: "ABC", Temp: temperature{21.5}, Level: 1},
+        }, {
+            name: "unmarshaler of a mapping",
+            input: "temp: {celsius: 10}\n",
+            into: new(customized),
+            expected: customized{Temp: temperature{10}},
+        }, {
+            name: "unmarshaler of a sequence item",
+            input: "[a, b]\n",
+            into: new([]upper),
+            expected: []upper{"A", "B"},
+        }, {
+            name: "unmarshaler of an alias",
+            input: "a: &x hot\nb: *x\n",
+            into: new(map[string]upper),
+            expected: map[string]upper{"a": "HOT", "b": "HOT"},
+        }, {
+            name: "unmarshaler error",
+            input: "{}\n",
+            into: new(broken),
+            err: errCustom,
+        }, {
+            name: "unmarshaler error in a field",
+            input: "x: [1]\n",
+            into: new(struct{ X broken `yaml:"x"` }),
+            err: errCustom,
+        }, {
+            name: "text unmarshaler error",
+            input: "level: medium\n",
+            into: new(customized),
+            err: errCustom,
+        }, {
+            name: "unmarshaler type mismatch",
+            input: "name: [a]\n",
+            into: new(customized),
+            fails: true,
+        }, {
+            name: "disabled",
+            input: "level: high\n",
+            opts: []interface{}{"nocustom"},
+            into: new(customized),
+            fails: true,
+        },
+    })
+}
+
+type aliased struct {
+    Defaults map[string]interface{} `yaml:"defaults"`
+    Web container `yaml:"web"`
+    List []container `yaml:"list"`
+}
+
+type converting struct {
+    A string `yaml:"a"`
+    B int `yaml:"b"`
+    C []int `yaml:"c"`
+}
+
+func TestDecodeAliases(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "mapping in a sequence",
+            input: "- &d {name: a, image: b}\n- *d\n",
+            into: new([]container),
+            expected: []container{{"a", "b"}, {"a", "b"}},
+        }, {
+            name: "generic map to a struct",
+            input: "defaults: &d {name: a, image: b}\nweb: *d\nlist: [*d]\n",
+            into: new(aliased),
+            expected: aliased{
+                Defaults: map[string]interface{}{"name": "a", "image": "b"},
+                Web: container{"a", "b"},
+                List: []container{{"a", "b"}},
+            },
+        }, {
+            name: "nested anchor",
+            input: "c: [1, &x 2]\nb: *x\n",
+            into: new(converting),
+            expected: converting{B: 2, C: []int{1, 2}},
+        }, {
+            name: "scalar converted",
+            input: "a: &x 10\nb: *x\n",
+            into: new(converting),
+            expected: converting{A: "10", B: 10},
+        }, {
+            name: "sequence to generic",
+            input: "a: &x [1, two]\nb: *x\n",
+            into: new(map[string]interface{}),
+            expected: map[string]interface{}{"a": []interface{}{1, "two"}, "b": []interface{}{1, "two"}},
+        }, {
+            name: "sequence to a scalar",
+            input: "c: &x [1]\nb: *x\n",
+            into: new(converting),
+            fails: true,
+        }, {
+            name: "text to a number",
+            input: "a: &x ten\nb: *x\n",
+            into: new(converting),
+            fails: true,
+        }, {
+            name: "undefined",
+            input: "a: *x\n",
+            into: new(converting),
+            fails: true,
+        },
+    })
+}
+
+func TestDecodeSharePointers(t *testing.T) {
+
+    input := []byte("a: &x {name: n}\nb: *x\n")
+
+    tests := []struct {
+        name string
+        opts []interface{}
+        shared bool
+    }{
+        { name: "copies", shared: false },
+        { name: "shared", opts: []interface{}{"share-pointers"}, shared: true },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            var v sharing
+            if err := Unmarshal(input, &v, tt.opts...); err != nil {
+                t.Fatal(err)
+            }
+            if v.A == nil || v.B == nil || *v.A != *v.B {
+                t.Fatalf("got %#v", v)
+            }
+            if (v.A == v.B) != tt.shared {
+                t.Errorf("got shared %v, expected %v", v.A == v.B, tt.shared)
+            }
+        })
+    }
+}
+
+type merged struct {
+    Name string `yaml:"name"`
+    Image string `yaml:"image"`
+    Replicas int `yaml:"replicas"`
+}
+
+func TestDecodeMergeKeys(t *testing.T) {
+
+    const lists = "a: &a {name: a, replicas: 1}\nb: &b {name: b, image: b}\nc:\n  <<: [*a, *b]\n"
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "struct",
+            input: "base: &b {image: i, replicas: 2}\nweb:\n  <<: *b\n  name: w\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(map[string]merged),
+            expected: map[string]merged{"base": {Image: "i", Replicas: 2}, "web": {"w", "i", 2}},
+        }, {
+            name: "generic map",
+            input: "base: &b {image: i}\nweb:\n  <<: *b\n  name: w\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(map[string]map[string]string),
+            expected: map[string]map[string]string{
+                "base": {"image": "i"},
+                "web": {"name": "w", "image": "i"},
+            },
+        }, {
+            name: "explicit keys win",
+            input: "base: &b {name: b, image: i}\nweb:\n  name: w\n  <<: *b\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(map[string]merged),
+            expected: map[string]merged{"base": {Name: "b", Image: "i"}, "web": {Name: "w", Image: "i"}},
+        }, {
+            name: "first of a list wins",
+            input: lists,
+            opts: []interface{}{"merge-keys"},
+            into: new(map[string]merged),
+            expected: map[string]merged{
+                "a": {Name: "a", Replicas: 1},
+                "b": {Name: "b", Image: "b"},
+                "c": {"a", "b", 1},
+            },
+        }, {
+            name: "anchored struct",
+            input: "- &s {name: s, image: i}\n- {<<: *s, name: t}\n",
+            opts: []interface{}{"merge-keys"},
+            into: new([]merged),
+            expected: []merged{{Name: "s", Image: "i"}, {Name: "t", Image: "i"}},
+        }, {
+            name: "inline mapping",
+            input: "<<: {name: n}\nimage: i\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(merged),
+            expected: merged{Name: "n", Image: "i"},
+        }, {
+            name: "always on for 1.1",
+            input: "%YAML 1.1\n---\nbase: &b {image: i}\nweb: {<<: *b}\n",
+            into: new(map[string]merged),
+            expected: map[string]merged{"base": {Image: "i"}, "web": {Image: "i"}},
+        }, {
+            name: "off by default",
+            input: "base: &b {image: i}\nweb: {<<: *b}\n",
+            into: new(map[string]map[string]interface{}),
+            expected: map[string]map[string]interface{}{
+                "base": {"image": "i"},
+                "web": {"<<": map[interface{}]interface{}{"image": "i"}},
+            },
+        }, {
+            name: "off by default to a struct",
+            input: "base: &b {image: i}\nweb: {<<: *b}\n",
+            into: new(map[string]merged),
+            fails: true,
+        }, {
+            name: "scalar",
+            input: "<<: x\nname: n\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(merged),
+            fails: true,
+        }, {
+            name: "list of scalars",
+            input: "<<: [x]\nname: n\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(map[string]interface{}),
+            fails: true,
+        }, {
+            name: "unknown field",
+            input: "<<: {other: 1}\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(merged),
+            fails: true,
+        }, {
+            name: "type mismatch",
+            input: "<<: {replicas: many}\n",
+            opts: []interface{}{"merge-keys"},
+            into: new(merged),
+            fails: true,
+        },
+    })
+}
+
+type port int
+
+func TestDecodeTypedKeys(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "int",
+            input: "1: a\n0x10: b\n-3: c\n",
+            into: new(map[int]string),
+            expected: map[int]string{1: "a", 16: "b", -3: "c"},
+        }, {
+            name: "named int",
+            input: "80: http\n443: https\n",
+            into: new(map[port]string),
+            expected: map[port]string{80: "http", 443: "https"},
+        }, {
+            name: "bool",
+            input: "true: 1\nfalse: 0\n",
+            into: new(map[bool]int),
+            expected: map[bool]int{true: 1, false: 0},
+        }, {
+            name: "bool in 1.1",
+            input: "%YAML 1.1\n---\nyes: 1\noff: 0\n",
+            into: new(map[bool]int),
+            expected: map[bool]int{true: 1, false: 0},
+        }, {
+            name: "float",
+            input: "1.5: a\n2: b\n",
+            into: new(map[float64]string),
+            expected: map[float64]string{1.5: "a", 2: "b"},
+        }, {
+            name: "text unmarshaler",
+            input: "low: 1\nhigh: 2\n",
+            into: new(map[level]int),
+            expected: map[level]int{1: 1, 2: 2},
+        }, {
+            name: "nested",
+            input: "1: {true: x}\n",
+            into: new(map[int]map[bool]string),
+            expected: map[int]map[bool]string{1: {true: "x"}},
+        }, {
+            name: "quoted json key",
+            input: `{"1": "a", "2": "b"}`,
+            opts: []interface{}{"schema=json"},
+            into: new(map[int]string),
+            expected: map[int]string{1: "a", 2: "b"},
+        }, {
+            name: "quoted key",
+            input: "'1': a\n",
+            into: new(map[int]string),
+            fails: true,
+        }, {
+            name: "text to an int",
+            input: "a: x\n",
+            into: new(map[int]string),
+            fails: true,
+        }, {
+            name: "out of range",
+            input: "300: x\n",
+            into: new(map[uint8]string),
+            fails: true,
+        }, {
+            name: "duplicate",
+            input: "1: a\n+1: b\n",
+            into: new(map[int]string),
+            fails: true,
+        }, {
+            name: "text unmarshaler error",
+            input: "medium: 1\n",
+            into: new(map[level]int),
+            err: errCustom,
+        },
+    })
+}
+
+func TestMarshalTypedKeys(t *testing.T) {
+
+    runEncodeTests(t, []encodeTest{
+        {
+            name: "int",
+            value: map[int]string{1: "a", -2: "b"},
+            back: new(map[int]string),
+            expected: map[int]string{1: "a", -2: "b"},
+        }, {
+            name: "bool",
+            value: map[bool]int{true: 1, false: 0},
+            back: new(map[bool]int),
+            expected: map[bool]int{true: 1, false: 0},
+        }, {
+            name: "float",
+            value: map[float64]string{1.5: "a", 2: "b"},
+            back: new(map[float64]string),
+            expected: map[float64]string{1.5: "a", 2: "b"},
+        }, {
+            name: "text marshaler",
+            value: map[level]int{1: 10, 2: 20},
+            back: new(map[level]int),
+            expected: map[level]int{1: 10, 2: 20},
+        }, {
+            name: "text marshaler as a string",
+            value: map[level]int{1: 10},
+            back: new(map[string]int),
+            expected: map[string]int{"low": 10},
+        }, {
+            name: "json",
+            value: map[int]bool{1: true, 2: false},
+            opts: []interface{}{"output-mode=json", "schema=json"},
+            back: new(map[int]bool),
+            expected: map[int]bool{1: true, 2: false},
+        },
+    })
+}
+
+func TestDecodeIntoExistingMap(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "typed map",
+            input: "a: 10\nc: 30\n",
+            into: &map[string]int{"a": 1, "b": 2},
+            expected: map[string]int{"a": 10, "b": 2, "c": 30},
+        }, {
+            name: "generic map",
+            input: "a: x\n1: y\n",
+            into: &map[interface{}]interface{}{"a": 1, 1: "one", "b": true},
+            expected: map[interface{}]interface{}{"a": "x", 1: "y", "b": true},
+        }, {
+            name: "concrete key map",
+            input: "1: x\n",
+            into: &map[int]string{1: "one", 2: "two"},
+            expected: map[int]string{1: "x", 2: "two"},
+        }, {
+            name: "merged keys overwrite too",
+            input: "b: &b {a: 3}\nm: {<<: *b}\n",
+            opts: []interface{}{"merge-keys"},
+            into: &map[string]map[string]int{"m": {"a": 1}},
+            expected: map[string]map[string]int{"b": {"a": 3}, "m": {"a": 3}},
+        }, {
+            name: "duplicate in the document",
+            input: "a: 1\na: 2\n",
+            into: &map[string]int{"b": 2},
+            fails: true,
+        }, {
+            name: "duplicate in the document of a generic map",
+            input: "a: 1\na: 2\n",
+            into: &map[interface{}]interface{}{"b": 2},
+            fails: true,
+        },
+    })
+}
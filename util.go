@@ -5,6 +5,8 @@ import (
     "fmt"
     "errors"
     "reflect"
+    "sort"
+    "strconv"
     "strings"
 )
 
@@ -30,29 +32,53 @@ func GetDebugfProvider(i interface{}) DebugfProvider {
 
 type Field struct {
     name, fieldName string
-    idx int
+    index []int             // the index sequence (longer for promoted fields)
+    tagged bool             // the name was given by a tag
     omitempty, ignored, asString bool
+    inline, flow bool
+    literal, folded, quoted bool    // string scalar style hints
 }
 
 type TypeInfo struct {
     t reflect.Type
+    tags []string           // the struct tags in order of precedence
     primed bool
     tagToField map[string]*Field    // when a match from tag to field was found
-    fields []*Field
+    fields []*Field         // the visible fields (embedded ones flattened)
+    inlineMap *Field        // the inline map collecting unmatched keys (if any)
 }
 
 func (ti *TypeInfo) String() string {
     return fmt.Sprintf("%v", ti.t.String())
 }
 
+type StructTagsProvider interface {
+    StructTags() []string
+}
+
+// by default the yaml tags have precedence over the json ones
+var DefaultStructTags = []string{"yaml", "json"}
+
+func GetStructTags(i interface{}) []string {
+    if i != nil {
+        stp, hasStp := i.(StructTagsProvider)
+        if hasStp {
+            return stp.StructTags()
+        }
+    }
+    return DefaultStructTags
+}
+
 type StructCache struct {
     dp DebugfProvider
+    tags []string           // the struct tags to look for
     types map[reflect.Type]*TypeInfo
 }
 
 func NewStructCache(i interface{}) *StructCache {
     return &StructCache{
         dp: GetDebugfProvider(i),
+        tags: GetStructTags(i),
         types: make(map[reflect.Type]*TypeInfo),
     }
 }
@@ -67,8 +93,9 @@ func (sc *StructCache) LookupType(t reflect.Type) *TypeInfo {
 func (sc *StructCache) NewType(t reflect.Type) *TypeInfo {
     ti := &TypeInfo {
         t: t,
+        tags: sc.tags,
         tagToField: make(map[string]*Field),
-        fields: make([]*Field, t.NumField()),
+        fields: make([]*Field, 0, t.NumField()),
     }
     sc.types[t] = ti
     ti.PrimeFieldCache()
@@ -83,58 +110,250 @@ func (sc *StructCache) LookupOrNewType(t reflect.Type) *TypeInfo {
     return sc.NewType(t)
 }
 
+// the type of the (possibly promoted) field in the struct type t
+func (f *Field) Type(t reflect.Type) reflect.Type {
+    for _, x := range f.index {
+        if t.Kind() == reflect.Ptr {
+            t = t.Elem()
+        }
+        t = t.Field(x).Type
+    }
+    return t
+}
+
+// parse a struct field according to the struct tags
+func (ti *TypeInfo) newField(field reflect.StructField, index []int) *Field {
+
+    name := field.Name
+    tagged := false
+    omitempty := false
+    // unexported fields can be neither set nor read
+    ignored := field.PkgPath != ""
+    asString := false
+    inline := false
+    flow := false
+    literal, folded, quoted := false, false, false
+
+    // unexported embedded structs are still searched for promoted fields
+    if field.Anonymous && ignored {
+        ft := field.Type
+        if ft.Kind() == reflect.Ptr {
+            ft = ft.Elem()
+        }
+        ignored = ft.Kind() != reflect.Struct
+    }
+
+    // the first tag found (in order of precedence) is used
+    for _, tagName := range ti.tags {
+        tag, ok := field.Tag.Lookup(tagName)
+        if !ok {
+            continue
+        }
+
+        tsplit := strings.Split(tag, ",")
+        first := tsplit[0]
+        if first == "-" && len(tsplit) == 1 {
+            ignored = true
+        } else {
+            // use this name for match (if given)
+            if first != "" {
+                name = first
+                tagged = true
+            }
+
+            for _, keyword := range(tsplit[1:]) {
+                switch keyword {
+                case "omitempty":
+                    omitempty = true
+                case "string":
+                    asString = true
+                case "inline":
+                    inline = true
+                case "flow":
+                    flow = true
+                case "literal":
+                    literal = true
+                case "folded":
+                    folded = true
+                case "quoted":
+                    quoted = true
+                }
+            }
+        }
+        break
+    }
+
+    return &Field{
+        name: name,
+        fieldName: field.Name,
+        index: index,
+        tagged: tagged,
+        omitempty: omitempty,
+        ignored: ignored,
+        asString: asString,
+        inline: inline,
+        flow: flow,
+        literal: literal,
+        folded: folded,
+        quoted: quoted,
+    }
+}
+
+// the fields are found following the encoding/json rules
+// embedded structs (and explicitly inline ones) are flattened, and
+// among fields with the same name the shallowest one wins, with
+// tagged fields preferred at the same depth; otherwise all are dropped
 func (ti *TypeInfo) PrimeFieldCache() {
 
     if ti.primed {
         return
     }
 
-    for i := 0; i < ti.t.NumField(); i++ {
-        field := ti.t.Field(i)
+    type embedded struct {
+        t reflect.Type
+        index []int
+    }
+
+    var fields []*Field
 
-        name := field.Name
-        omitempty := false
-        ignored := false
-        asString := false
+    current := []embedded{}
+    next := []embedded{{t: ti.t}}
+    visited := make(map[reflect.Type]uvoid)
 
-        if json, ok := field.Tag.Lookup("json"); ok {
-            jsplit := strings.Split(json, ",")
-            first := jsplit[0]
-            if first == "-" && len(jsplit) == 1 {
-                ignored = true
-            } else {
-                // use this name for match
-                name = first
+    // breadth first, one depth level at a time
+    for len(next) > 0 {
+        current, next = next, nil
+
+        for _, emb := range current {
+            if _, seen := visited[emb.t]; seen {
+                continue
+            }
+            visited[emb.t] = uvoid{}
+
+            for i := 0; i < emb.t.NumField(); i++ {
+                field := emb.t.Field(i)
+
+                index := make([]int, len(emb.index) + 1)
+                copy(index, emb.index)
+                index[len(emb.index)] = i
+
+                f := ti.newField(field, index)
+                if f.ignored {
+                    continue
+                }
 
-                for _, keyword := range(jsplit[1:]) {
-                    switch keyword {
-                    case "omitempty":
-                        omitempty = true
-                    case "string":
-                        asString = true
+                ft := field.Type
+                if ft.Kind() == reflect.Ptr && ft.Name() == "" {
+                    ft = ft.Elem()
+                }
+
+                // embedded without a name, or explicitly inline structs
+                if ft.Kind() == reflect.Struct && ((field.Anonymous && !f.tagged) || f.inline) {
+                    next = append(next, embedded{t: ft, index: index})
+                    continue
+                }
+
+                // the (shallowest) inline string keyed map collects the rest
+                if f.inline && ft.Kind() == reflect.Map && ft.Key().Kind() == reflect.String {
+                    if ti.inlineMap == nil {
+                        ti.inlineMap = f
                     }
+                    continue
                 }
+
+                fields = append(fields, f)
             }
         }
+    }
 
-        f := &Field{
-            name: name,
-            fieldName: field.Name,
-            idx: i,
-            omitempty: omitempty,
-            ignored: ignored,
-            asString: asString,
+    // sort by name, depth, tagged first, and index sequence
+    sort.SliceStable(fields, func(i, j int) bool {
+        fi, fj := fields[i], fields[j]
+        if fi.name != fj.name {
+            return fi.name < fj.name
+        }
+        if len(fi.index) != len(fj.index) {
+            return len(fi.index) < len(fj.index)
         }
+        if fi.tagged != fj.tagged {
+            return fi.tagged
+        }
+        return indexLess(fi.index, fj.index)
+    })
+
+    // keep the dominant field of each name
+    for i := 0; i < len(fields); {
+        j := i + 1
+        for j < len(fields) && fields[j].name == fields[i].name {
+            j++
+        }
+        group := fields[i:j]
+        if len(group) == 1 ||
+           len(group[0].index) != len(group[1].index) ||
+           group[0].tagged != group[1].tagged {
+            ti.fields = append(ti.fields, group[0])
+        }
+        i = j
+    }
 
-        // insert to the field cache
-        ti.tagToField[name] = f
-        ti.fields[i] = f
+    // and back to the declaration order
+    sort.Slice(ti.fields, func(i, j int) bool {
+        return indexLess(ti.fields[i].index, ti.fields[j].index)
+    })
+
+    // insert to the field cache
+    for _, f := range ti.fields {
+        ti.tagToField[f.name] = f
     }
 
     ti.primed = true
 }
 
-func (ti *TypeInfo) FieldByName(name string, rv *reflect.Value) (*reflect.Value, *Field) {
+func indexLess(a, b []int) bool {
+    for k := 0; k < len(a) && k < len(b); k++ {
+        if a[k] != b[k] {
+            return a[k] < b[k]
+        }
+    }
+    return len(a) < len(b)
+}
+
+// address a (possibly promoted) field for setting
+// nil embedded pointers on the way are allocated
+func FieldByIndexAlloc(rv reflect.Value, index []int) (reflect.Value, error) {
+
+    for i, x := range index {
+        if i > 0 && rv.Kind() == reflect.Ptr {
+            if rv.IsNil() {
+                if !rv.CanSet() {
+                    return reflect.Value{}, errors.New(fmt.Sprintf("cannot allocate embedded pointer to unexported %v", rv.Type().Elem()))
+                }
+                rv.Set(reflect.New(rv.Type().Elem()))
+            }
+            rv = rv.Elem()
+        }
+        rv = rv.Field(x)
+    }
+    return rv, nil
+}
+
+// address a (possibly promoted) field for reading
+// returns an invalid value if a nil embedded pointer is on the way
+func FieldByIndexRead(rv reflect.Value, index []int) reflect.Value {
+
+    for i, x := range index {
+        if i > 0 && rv.Kind() == reflect.Ptr {
+            if rv.IsNil() {
+                return reflect.Value{}
+            }
+            rv = rv.Elem()
+        }
+        rv = rv.Field(x)
+    }
+    return rv
+}
+
+func (ti *TypeInfo) FieldByName(name string, rv *reflect.Value) (*reflect.Value, *Field, error) {
 
     // some sanity checks
     if rv == nil || !(*rv).IsValid() || (*rv).Kind() != reflect.Struct {
@@ -160,12 +379,43 @@ func (ti *TypeInfo) FieldByName(name string, rv *reflect.Value) (*reflect.Value,
 
     // not found, or ignored
     if !ok || uf.ignored {
-        return nil, nil
+        return nil, nil, nil
     }
 
     // OK, this is the one
-    rvf := rv.Field(uf.idx)
-    return &rvf, uf
+    rvf, err := FieldByIndexAlloc(*rv, uf.index)
+    if err != nil {
+        return nil, nil, err
+    }
+    return &rvf, uf, nil
+}
+
+// whether a value is considered empty for omitempty (as encoding/json)
+func IsEmptyValue(rv reflect.Value) bool {
+
+    switch rv.Kind() {
+    case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+        return rv.Len() == 0
+    case reflect.Bool:
+        return !rv.Bool()
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return rv.Int() == 0
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return rv.Uint() == 0
+    case reflect.Float32, reflect.Float64:
+        return rv.Float() == 0
+    case reflect.Interface, reflect.Ptr:
+        return rv.IsNil()
+    }
+    return false
+}
+
+// the string pointed to, or an empty one for nil (i.e. a null scalar)
+func stringOrEmpty(strp *string) string {
+    if strp == nil {
+        return ""
+    }
+    return *strp
 }
 
 func SettableValueOf(i interface{}) reflect.Value {
@@ -187,7 +437,7 @@ func IndirectPointer(rv *reflect.Value) (*reflect.Value, error) {
         }
         rvt := rv.Elem()
         if !rvt.IsValid() {
-            return nil, errors.New(fmt.Sprintf("deref pointer value is invalid: %v"))
+            return nil, errors.New(fmt.Sprintf("deref pointer value is invalid: %v", rv.Kind()))
         }
         rv = &rvt
     }
@@ -195,20 +445,136 @@ func IndirectPointer(rv *reflect.Value) (*reflect.Value, error) {
     return rv, nil
 }
 
+// aliases are resolved on the value itself, so that pointers can be shared
+func IndirectPointerUnlessAlias(event *Event, rv *reflect.Value) (*reflect.Value, error) {
+    if event.Type() == Alias {
+        return rv, nil
+    }
+    return IndirectPointer(rv)
+}
+
+// check whether a value can be used as a map key without panicking
 func IsHashable(rv reflect.Value) bool {
 
-    for {
-        k := rv.Kind()
-        if (k > reflect.Invalid && k < reflect.Array) ||
-            k == reflect.Ptr || k == reflect.UnsafePointer || k == reflect.String {
+    switch k := rv.Kind(); k {
+    case reflect.Invalid:
+        // a nil interface
+        return true
+
+    case reflect.Interface:
+        if rv.IsNil() {
             return true
         }
-        if k != reflect.Interface {
-            break
+        return IsHashable(rv.Elem())
+
+    case reflect.Array:
+        for i := 0; i < rv.Len(); i++ {
+            if !IsHashable(rv.Index(i)) {
+                return false
+            }
         }
-        rv = rv.Elem()
+        return true
+
+    case reflect.Struct:
+        for i := 0; i < rv.NumField(); i++ {
+            if !IsHashable(rv.Field(i)) {
+                return false
+            }
+        }
+        return true
+
+    case reflect.Slice, reflect.Map, reflect.Func:
+        return false
     }
 
-    return false
+    // all the rest are comparable
+    return true
 }
 
+// the canonical form of a mapping used as a key of a generic mapping
+type ComplexKey string
+
+// convert an unhashable generic key to a hashable canonical form
+// sequences become arrays of their (canonical) items, while mappings
+// become a ComplexKey containing their canonical flow text
+func CanonicalKey(rv reflect.Value) (reflect.Value, error) {
+
+    if IsHashable(rv) {
+        return rv, nil
+    }
+
+    switch rv.Kind() {
+    case reflect.Interface, reflect.Ptr:
+        return CanonicalKey(rv.Elem())
+
+    case reflect.Slice, reflect.Array:
+        av := reflect.New(reflect.ArrayOf(rv.Len(), genericIfaceType)).Elem()
+        for i := 0; i < rv.Len(); i++ {
+            item, err := CanonicalKey(rv.Index(i))
+            if err != nil {
+                return reflect.Value{}, err
+            }
+            av.Index(i).Set(item)
+        }
+        return av, nil
+
+    case reflect.Map:
+        text, err := canonicalText(rv)
+        if err != nil {
+            return reflect.Value{}, err
+        }
+        return reflect.ValueOf(ComplexKey(text)), nil
+    }
+
+    return reflect.Value{}, errors.New(fmt.Sprintf("cannot hash key of type %s", rv.Type()))
+}
+
+// the canonical text of a generic value; mapping keys are sorted
+func canonicalText(rv reflect.Value) (string, error) {
+
+    switch rv.Kind() {
+    case reflect.Invalid:
+        return "~", nil
+
+    case reflect.Interface, reflect.Ptr:
+        if rv.IsNil() {
+            return "~", nil
+        }
+        return canonicalText(rv.Elem())
+
+    case reflect.String:
+        return strconv.Quote(rv.String()), nil
+
+    case reflect.Slice, reflect.Array:
+        items := make([]string, rv.Len())
+        for i := 0; i < rv.Len(); i++ {
+            text, err := canonicalText(rv.Index(i))
+            if err != nil {
+                return "", err
+            }
+            items[i] = text
+        }
+        return "[" + strings.Join(items, ", ") + "]", nil
+
+    case reflect.Map:
+        items := make([]string, 0, rv.Len())
+        for _, key := range rv.MapKeys() {
+            ktext, err := canonicalText(key)
+            if err != nil {
+                return "", err
+            }
+            vtext, err := canonicalText(rv.MapIndex(key))
+            if err != nil {
+                return "", err
+            }
+            items = append(items, ktext + ": " + vtext)
+        }
+        sort.Strings(items)
+        return "{" + strings.Join(items, ", ") + "}", nil
+
+    case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+        return "", errors.New(fmt.Sprintf("cannot hash key of type %s", rv.Type()))
+    }
+
+    return fmt.Sprintf("%v", rv.Interface()), nil
+}
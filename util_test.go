@@ -0,0 +1,195 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestCanonicalKey(t *testing.T) {
+
+    tests := []struct {
+        name string
+        key interface{}
+        expected interface{}
+        fails bool
+    }{
+        {
+            name: "scalar",
+            key: "a",
+            expected: "a",
+        }, {
+            name: "sequence",
+            key: []interface{}{"a", 1},
+            expected: [2]interface{}{"a", 1},
+        }, {
+            name: "nested sequence",
+            key: []interface{}{"a", []interface{}{"b"}},
+            expected: [2]interface{}{"a", [1]interface{}{"b"}},
+        }, {
+            name: "mapping",
+            key: map[interface{}]interface{}{"b": 2, "a": 1},
+            expected: ComplexKey(`{"a": 1, "b": 2}`),
+        }, {
+            name: "mapping in sequence",
+            key: []interface{}{map[interface{}]interface{}{"a": nil}},
+            expected: [1]interface{}{ComplexKey(`{"a": ~}`)},
+        }, {
+            name: "function",
+            key: []interface{}{func() {}},
+            fails: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            ckey, err := CanonicalKey(reflect.ValueOf(&tt.key).Elem())
+            if tt.fails {
+                if err == nil {
+                    t.Fatalf("expected an error, got %#v", ckey.Interface())
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !IsHashable(ckey) {
+                t.Errorf("%#v is not hashable", ckey.Interface())
+            }
+            if got := ckey.Interface(); !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %#v, expected %#v", got, tt.expected)
+            }
+        })
+    }
+}
+
+type tagged struct {
+    Both int `yaml:"y_both" json:"j_both"`
+    JSON int `json:"j_only"`
+    YAML int `yaml:"y_only"`
+    None int
+    Skip int `yaml:"-" json:"j_skip"`
+    Empty int `yaml:",omitempty" json:"j_empty"`
+}
+
+func TestStructTagPrecedence(t *testing.T) {
+
+    tests := []struct {
+        name string
+        tags string
+        expected map[string]string  // name -> field
+    }{
+        {
+            name: "yaml then json",
+            tags: "yaml,json",
+            expected: map[string]string{
+                "y_both": "Both", "j_only": "JSON", "y_only": "YAML", "None": "None", "Empty": "Empty",
+            },
+        }, {
+            name: "json then yaml",
+            tags: "json,yaml",
+            expected: map[string]string{
+                "j_both": "Both", "j_only": "JSON", "y_only": "YAML", "None": "None", "j_skip": "Skip", "j_empty": "Empty",
+            },
+        }, {
+            name: "json only",
+            tags: "json",
+            expected: map[string]string{
+                "j_both": "Both", "j_only": "JSON", "YAML": "YAML", "None": "None", "j_skip": "Skip", "j_empty": "Empty",
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+
+            o := OptionsDefault
+            o.StructTag = tt.tags
+
+            ti := NewStructCache(&o).NewType(reflect.TypeOf(tagged{}))
+
+            got := make(map[string]string)
+            for _, f := range ti.fields {
+                got[f.name] = f.fieldName
+            }
+            if !reflect.DeepEqual(got, tt.expected) {
+                t.Errorf("got %v, expected %v", got, tt.expected)
+            }
+        })
+    }
+}
+
+func TestIsEmptyValue(t *testing.T) {
+
+    var nilp *int
+    var nili interface{}
+
+    tests := []struct {
+        value interface{}
+        empty bool
+    }{
+        { "", true }, { "a", false },
+        { 0, true }, { 1, false },
+        { uint8(0), true }, { uint8(1), false },
+        { 0.0, true }, { 0.5, false },
+        { false, true }, { true, false },
+        { []int{}, true }, { []int{0}, false },
+        { map[string]int{}, true }, { map[string]int{"a": 0}, false },
+        { [0]int{}, true }, { [1]int{}, false },
+        { nilp, true }, { new(int), false },
+        { &nili, false },
+        { struct{}{}, false },
+    }
+
+    for _, tt := range tests {
+        if empty := IsEmptyValue(reflect.ValueOf(tt.value)); empty != tt.empty {
+            t.Errorf("%#v: got %v, expected %v", tt.value, empty, tt.empty)
+        }
+    }
+}
+
+type Base struct {
+    ID int `yaml:"id"`
+    Kind string `yaml:"kind"`
+}
+
+type Meta struct {
+    Kind string `yaml:"kind"`
+    Note string `yaml:"note"`
+}
+
+type embedding struct {
+    Base
+    *Meta
+    Name string `yaml:"name"`
+    Spec struct {
+        Size int `yaml:"size"`
+    } `yaml:",inline"`
+    Rest map[string]interface{} `yaml:",inline"`
+}
+
+func TestStructFieldFlattening(t *testing.T) {
+
+    ti := NewStructCache(&OptionsDefault).NewType(reflect.TypeOf(embedding{}))
+
+    got := make(map[string][]int)
+    for _, f := range ti.fields {
+        got[f.name] = f.index
+    }
+
+    // kind is both in Base and Meta at the same depth, so it's dropped
+    expected := map[string][]int{
+        "id": {0, 0},
+        "note": {1, 1},
+        "name": {2},
+        "size": {3, 0},
+    }
+    if !reflect.DeepEqual(got, expected) {
+        t.Errorf("got %v, expected %v", got, expected)
+    }
+
+    if ti.inlineMap == nil || ti.inlineMap.fieldName != "Rest" {
+        t.Errorf("expected Rest as the inline map, got %v", ti.inlineMap)
+    }
+}
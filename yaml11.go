@@ -0,0 +1,125 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "math"
+    "errors"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// the YAML 1.1 type repository numbers (https://yaml.org/type/)
+// binary, octal, decimal, hex and sexagesimal (base 60) integers,
+// all of them with optional underscores
+var yaml11IntRe = regexp.MustCompile(`^[-+]?(0b[0-1_]+|0[0-7_]+|0|[1-9][0-9_]*|0x[0-9a-fA-F_]+|[1-9][0-9_]*(:[0-5]?[0-9])+)$`)
+
+// decimal and sexagesimal floats, infinities and not a number
+var yaml11FloatRe = regexp.MustCompile(`^([-+]?([0-9][0-9_]*\.[0-9_]*|\.[0-9_]+)([eE][-+]?[0-9]+)?|` +
+                                       `[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*|` +
+                                       `[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+
+// split the sign off a number
+func splitSign(str string) (string, string) {
+    if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+        return str[:1], str[1:]
+    }
+    return "", str
+}
+
+// convert any of the 1.1 integer forms to decimal
+func yaml11IntDecimal(str string) (string, error) {
+
+    if !yaml11IntRe.MatchString(str) {
+        return "", errors.New(fmt.Sprintf("invalid integer %s", str))
+    }
+
+    sign, str := splitSign(str)
+    str = strings.Replace(str, "_", "", -1)
+
+    var value uint64
+    var err error
+
+    switch {
+    case strings.Contains(str, ":"):
+        for _, part := range strings.Split(str, ":") {
+            var digit uint64
+            if digit, err = strconv.ParseUint(part, 10, 64); err != nil {
+                break
+            }
+            if value > (math.MaxUint64 - digit) / 60 {
+                return "", errors.New(fmt.Sprintf("integer %s out of range", str))
+            }
+            value = value * 60 + digit
+        }
+    case strings.HasPrefix(str, "0b"):
+        value, err = strconv.ParseUint(str[2:], 2, 64)
+    case strings.HasPrefix(str, "0x"):
+        value, err = strconv.ParseUint(str[2:], 16, 64)
+    case len(str) > 1 && str[0] == '0':
+        value, err = strconv.ParseUint(str[1:], 8, 64)
+    case str == "0":
+        value = 0
+    default:
+        value, err = strconv.ParseUint(str, 10, 64)
+    }
+
+    if err != nil {
+        return "", err
+    }
+
+    // the sign is kept so that strconv does the range checks
+    if sign == "-" {
+        return sign + strconv.FormatUint(value, 10), nil
+    }
+    return strconv.FormatUint(value, 10), nil
+}
+
+// convert any of the 1.1 float forms to text strconv understands
+func yaml11FloatText(str string) (string, error) {
+
+    if !yaml11FloatRe.MatchString(str) {
+        return "", errors.New(fmt.Sprintf("invalid float %s", str))
+    }
+
+    // the special values are common to the YAML schemas
+    if _, isSpecial := floatSpecial(str); isSpecial {
+        return str, nil
+    }
+
+    sign, str := splitSign(str)
+    str = strings.Replace(str, "_", "", -1)
+
+    if !strings.Contains(str, ":") {
+        return sign + str, nil
+    }
+
+    // sexagesimal, all parts but the last are integers
+    parts := strings.Split(str, ":")
+    value := 0.0
+    for i, part := range parts {
+        digit, err := strconv.ParseFloat(part, 64)
+        if err != nil || (i < len(parts) - 1 && strings.Contains(part, ".")) {
+            return "", errors.New(fmt.Sprintf("invalid float %s", str))
+        }
+        value = value * 60 + digit
+    }
+    if sign == "-" {
+        value = -value
+    }
+    return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+// the infinities and not a number of the YAML schemas
+func floatSpecial(str string) (float64, bool) {
+    switch str {
+    case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+        return math.Inf(1), true
+    case "-.inf", "-.Inf", "-.INF":
+        return math.Inf(-1), true
+    case ".nan", ".NaN", ".NAN":
+        return math.NaN(), true
+    }
+    return 0, false
+}
@@ -0,0 +1,175 @@
+// vim: tabstop=4 shiftwidth=4 expandtab
+package fyaml
+
+import (
+    "math"
+    "testing"
+)
+
+func TestYaml11IntDecimal(t *testing.T) {
+
+    tests := []struct {
+        str string
+        expected string
+        fails bool
+    }{
+        { str: "0", expected: "0" },
+        { str: "685230", expected: "685230" },
+        { str: "+685_230", expected: "685230" },
+        { str: "-685230", expected: "-685230" },
+        { str: "02472256", expected: "685230" },
+        { str: "0x_0A_74_AE", expected: "685230" },
+        { str: "0b1010_0111_0100_1010_1110", expected: "685230" },
+        { str: "190:20:30", expected: "685230" },
+        { str: "-1:30", expected: "-90" },
+        { str: "0777", expected: "511" },
+        { str: "18446744073709551615", expected: "18446744073709551615" },
+        { str: "18446744073709551616", fails: true },
+        { str: "089", fails: true },
+        { str: "0o17", fails: true },
+        { str: "0b102", fails: true },
+        { str: "1:60", fails: true },
+        { str: "1.5", fails: true },
+        { str: "abc", fails: true },
+        { str: "", fails: true },
+    }
+
+    for _, tt := range tests {
+        str, err := yaml11IntDecimal(tt.str)
+        if tt.fails {
+            if err == nil {
+                t.Errorf("%q: expected an error, got %s", tt.str, str)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", tt.str, err)
+            continue
+        }
+        if str != tt.expected {
+            t.Errorf("%q: got %s, expected %s", tt.str, str, tt.expected)
+        }
+    }
+}
+
+func TestYaml11FloatText(t *testing.T) {
+
+    tests := []struct {
+        str string
+        expected string
+        fails bool
+    }{
+        { str: "6.8523015e+5", expected: "6.8523015e+5" },
+        { str: "685.230_15e+03", expected: "685.23015e+03" },
+        { str: "685_230.15", expected: "685230.15" },
+        { str: "-1.5", expected: "-1.5" },
+        { str: ".5", expected: ".5" },
+        { str: "1.", expected: "1." },
+        { str: "190:20:30.15", expected: "685230.15" },
+        { str: "-1:30.5", expected: "-90.5" },
+        { str: ".inf", expected: ".inf" },
+        { str: "-.Inf", expected: "-.Inf" },
+        { str: ".NaN", expected: ".NaN" },
+        { str: "1", fails: true },
+        { str: "1e5", fails: true },
+        { str: ".nAn", fails: true },
+        { str: "a.5", fails: true },
+    }
+
+    for _, tt := range tests {
+        str, err := yaml11FloatText(tt.str)
+        if tt.fails {
+            if err == nil {
+                t.Errorf("%q: expected an error, got %s", tt.str, str)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", tt.str, err)
+            continue
+        }
+        if str != tt.expected {
+            t.Errorf("%q: got %s, expected %s", tt.str, str, tt.expected)
+        }
+    }
+}
+
+func TestFloatSpecial(t *testing.T) {
+
+    for _, str := range []string{".inf", ".Inf", ".INF", "+.inf"} {
+        if f, ok := floatSpecial(str); !ok || !math.IsInf(f, 1) {
+            t.Errorf("%s: got %v, %v", str, f, ok)
+        }
+    }
+    for _, str := range []string{"-.inf", "-.Inf", "-.INF"} {
+        if f, ok := floatSpecial(str); !ok || !math.IsInf(f, -1) {
+            t.Errorf("%s: got %v, %v", str, f, ok)
+        }
+    }
+    for _, str := range []string{".nan", ".NaN", ".NAN"} {
+        if f, ok := floatSpecial(str); !ok || !math.IsNaN(f) {
+            t.Errorf("%s: got %v, %v", str, f, ok)
+        }
+    }
+    for _, str := range []string{"inf", ".iNf", "-.nan", "1.0"} {
+        if _, ok := floatSpecial(str); ok {
+            t.Errorf("%s: special", str)
+        }
+    }
+}
+
+type legacy struct {
+    Enabled bool `yaml:"enabled"`
+    Mode int `yaml:"mode"`
+    Size uint32 `yaml:"size"`
+    Time int `yaml:"time"`
+    Ratio float64 `yaml:"ratio"`
+}
+
+func TestDecodeYaml11(t *testing.T) {
+
+    runDecodeTests(t, []decodeTest{
+        {
+            name: "typed",
+            input: "%YAML 1.1\n---\nenabled: on\nmode: 0755\nsize: 1_000_000\ntime: 1:30:00\nratio: 1_000.5\n",
+            into: new(legacy),
+            expected: legacy{Enabled: true, Mode: 0755, Size: 1000000, Time: 5400, Ratio: 1000.5},
+        }, {
+            name: "generic",
+            input: "%YAML 1.1\n---\n[yes, No, off, y, 0b1010, 0x1F, 017, -1:30, 1_000, .Inf, ~, 0o17, 1:30.5]\n",
+            into: new([]interface{}),
+            expected: []interface{}{true, false, false, true, 10, 31, 15, -90, 1000, math.Inf(1), nil, "0o17", 90.5},
+        }, {
+            name: "by option",
+            input: "[yes, 0777]\n",
+            opts: []interface{}{"schema=1.1"},
+            into: new([]interface{}),
+            expected: []interface{}{true, 511},
+        }, {
+            name: "core is not affected",
+            input: "[yes, on, 1_000, 0o17, 1:30]\n",
+            into: new([]interface{}),
+            expected: []interface{}{"yes", "on", "1_000", 15, "1:30"},
+        }, {
+            name: "quoted are strings",
+            input: "%YAML 1.1\n---\n['yes', \"0777\"]\n",
+            into: new([]interface{}),
+            expected: []interface{}{"yes", "0777"},
+        }, {
+            name: "bool to an int",
+            input: "%YAML 1.1\n---\nmode: yes\n",
+            into: new(legacy),
+            fails: true,
+        }, {
+            name: "out of range",
+            input: "%YAML 1.1\n---\nsize: 0x1_0000_0000\n",
+            into: new(legacy),
+            fails: true,
+        }, {
+            name: "negative to unsigned",
+            input: "%YAML 1.1\n---\nsize: -0b1\n",
+            into: new(legacy),
+            fails: true,
+        },
+    })
+}